@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/micke/kubeconsole/pkg/console"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reviewNamespace string
+)
+
+var approveCmd = &cobra.Command{
+	Use:   "approve [environment] [pod]",
+	Short: "Approves a console pod awaiting approval by another user",
+	Example: `# Approve a console pod in the production environment
+kubeconsole approve production kubeconsole-x7k2p -n billing`,
+	Run: func(cmd *cobra.Command, args []string) {
+		K8sClient.SelectContext(args[0])
+
+		if err := console.Approve(K8sClient, reviewNamespaceOrDefault(), args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error approving pod: %s\n", err)
+			os.Exit(1)
+		}
+	},
+	Args:              reviewArgs,
+	ValidArgsFunction: reviewValidArgs,
+}
+
+var denyCmd = &cobra.Command{
+	Use:   "deny [environment] [pod]",
+	Short: "Denies a console pod awaiting approval by another user, deleting it",
+	Example: `# Deny a console pod in the production environment
+kubeconsole deny production kubeconsole-x7k2p -n billing`,
+	Run: func(cmd *cobra.Command, args []string) {
+		K8sClient.SelectContext(args[0])
+
+		if err := console.Deny(K8sClient, reviewNamespaceOrDefault(), args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error denying pod: %s\n", err)
+			os.Exit(1)
+		}
+	},
+	Args:              reviewArgs,
+	ValidArgsFunction: reviewValidArgs,
+}
+
+func reviewArgs(cmd *cobra.Command, args []string) error {
+	if KubeconfigErr != nil {
+		return KubeconfigErr
+	}
+
+	if len(args) != 2 {
+		return errors.New("requires a environment and a pod argument")
+	}
+
+	// If no context with the specified name is found
+	if K8sClient.Contexts[args[0]] == nil {
+		return fmt.Errorf("invalid environment specified: %s, available environments are: %v", args[0], strings.Join(K8sClient.ContextNames(), ", "))
+	}
+
+	return nil
+}
+
+func reviewValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	// Completing context names
+	case 0:
+		return K8sClient.ContextNamesWithPrefix(toComplete), cobra.ShellCompDirectiveNoFileComp
+	// Completing the pods awaiting approval
+	case 1:
+		K8sClient.SelectContext(args[0])
+		return console.PodNamesAwaitingApprovalWithPrefix(K8sClient, reviewNamespaceOrDefault(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func reviewNamespaceOrDefault() string {
+	if reviewNamespace == "" {
+		return K8sClient.ContextNamespace()
+	}
+
+	return reviewNamespace
+}
+
+func init() {
+	rootCmd.AddCommand(approveCmd)
+	rootCmd.AddCommand(denyCmd)
+
+	for _, cmd := range []*cobra.Command{approveCmd, denyCmd} {
+		cmd.Flags().StringVarP(&reviewNamespace, "namespace", "n", "", "Namespace of the pod (default the namespace of the context)")
+	}
+}
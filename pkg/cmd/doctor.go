@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/micke/kubeconsole/pkg/console"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [environment...]",
+	Short: "Checks that kubeconsole is able to run consoles in the environments",
+	Example: `# Check all environments
+kubeconsole doctor
+# Check the production environment with a custom selector
+kubeconsole doctor production -l app=console`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var environments []string
+
+		if len(args) > 0 {
+			environments = args
+		} else {
+			environments = K8sClient.ContextNames()
+		}
+
+		if !console.Doctor(K8sClient, Kubeconfig, KubeconfigErr, environments, options) {
+			os.Exit(1)
+		}
+	},
+	Args: func(cmd *cobra.Command, args []string) error {
+		for _, environment := range args {
+			// If no context with the specified name is found
+			if KubeconfigErr == nil && K8sClient.Contexts[environment] == nil {
+				return fmt.Errorf("invalid environment specified: %s, available environments are: %v", environment, strings.Join(K8sClient.ContextNames(), ", "))
+			}
+		}
+
+		return nil
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		// Completing context names
+		return K8sClient.ContextNamesWithPrefix(toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVarP(&options.LabelSelector, "selector", "l", "process=console", "Label selector used to filter the console sources, works the same as the -l flag for kubectl")
+	doctorCmd.Flags().StringSliceVar(&options.Kinds, "kind", nil, "Kinds of resources to use as console sources (default all kinds that can be listed)")
+	doctorCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace to check permissions in and search for console sources (default the namespace of the context)")
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/micke/kubeconsole/pkg/console"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scaffoldOptions console.ScaffoldOptions
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init [environment] [deployment]",
+	Short: "Creates a console deployment based on an app deployment",
+	Example: `# Print a console deployment based on the web deployment in the production environment
+kubeconsole init production web
+# Create the console deployment in the cluster
+kubeconsole init production web --apply`,
+	Run: func(cmd *cobra.Command, args []string) {
+		K8sClient.SelectContext(args[0])
+
+		if err := console.Scaffold(K8sClient, args[1], scaffoldOptions); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating console deployment: %s\n", err)
+			os.Exit(1)
+		}
+	},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if KubeconfigErr != nil {
+			return KubeconfigErr
+		}
+
+		if len(args) != 2 {
+			return errors.New("requires a environment and a deployment argument")
+		}
+
+		// If no context with the specified name is found
+		if K8sClient.Contexts[args[0]] == nil {
+			return fmt.Errorf("invalid environment specified: %s, available environments are: %v", args[0], strings.Join(K8sClient.ContextNames(), ", "))
+		}
+
+		if !slices.Contains(console.OutputFormats, scaffoldOptions.Output) {
+			return fmt.Errorf("invalid output format: %s, valid formats are: %s", scaffoldOptions.Output, strings.Join(console.OutputFormats, ", "))
+		}
+
+		return nil
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		switch len(args) {
+		// Completing context names
+		case 0:
+			return K8sClient.ContextNamesWithPrefix(toComplete), cobra.ShellCompDirectiveNoFileComp
+		// Completing deployment names
+		case 1:
+			K8sClient.SelectContext(args[0])
+			namespaces := []string{scaffoldOptions.Namespace}
+			if scaffoldOptions.Namespace == "" {
+				namespaces = []string{K8sClient.ContextNamespace()}
+			}
+			return K8sClient.SourceNamesWithPrefix(toComplete, "", []string{"deployment"}, namespaces), cobra.ShellCompDirectiveNoFileComp
+		default:
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+
+	initCmd.Flags().StringVarP(&scaffoldOptions.Namespace, "namespace", "n", "", "Namespace of the app deployment (default the namespace of the context)")
+	initCmd.Flags().StringVar(&scaffoldOptions.Name, "name", "", "Name of the console deployment (default the name of the app deployment suffixed with -console)")
+	initCmd.Flags().StringVarP(&scaffoldOptions.LabelSelector, "selector", "l", "process=console", "Label selector that kubeconsole uses to find the console deployment, added as labels to the console deployment")
+	initCmd.Flags().StringVar(&scaffoldOptions.ContainerName, "container", "", "Container to set the console command on. If omitted, use the kubectl.kubernetes.io/default-container annotation or the first container")
+	initCmd.Flags().BoolVar(&scaffoldOptions.Apply, "apply", false, "Create the console deployment in the cluster instead of printing it")
+	initCmd.Flags().StringVarP(&scaffoldOptions.Output, "output", "o", "yaml", "Output format when printing the console deployment. One of: yaml, json")
+	initCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(console.OutputFormats, cobra.ShellCompDirectiveNoFileComp))
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/micke/kubeconsole/pkg/console"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintOutput string
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [environment]",
+	Short: "Checks the console sources for common problems",
+	Example: `# Lint the console deployments in the production environment
+kubeconsole lint production
+# Lint a local manifest and output the results as JSON
+kubeconsole lint -f console.yaml -o json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		environment := ""
+		if len(args) > 0 {
+			environment = args[0]
+		}
+
+		if !console.Lint(K8sClient, environment, options, lintOutput) {
+			os.Exit(1)
+		}
+	},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("accepts at most one environment argument")
+		}
+
+		if len(args) == 0 && options.FromFile == "" {
+			return errors.New("requires a environment argument or a manifest passed with --from-file")
+		}
+
+		// The kubeconfig is only needed when linting an environment
+		if len(args) > 0 && KubeconfigErr != nil {
+			return KubeconfigErr
+		}
+
+		// If no context with the specified name is found
+		if len(args) > 0 && K8sClient.Contexts[args[0]] == nil {
+			return fmt.Errorf("invalid environment specified: %s, available environments are: %v", args[0], strings.Join(K8sClient.ContextNames(), ", "))
+		}
+
+		if !slices.Contains(console.LintFormats, lintOutput) {
+			return fmt.Errorf("invalid output format: %s, valid formats are: %s", lintOutput, strings.Join(console.LintFormats, ", "))
+		}
+
+		return nil
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		// Completing context names
+		return K8sClient.ContextNamesWithPrefix(toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().StringVarP(&options.LabelSelector, "selector", "l", "process=console", "Label selector used to filter the console sources, works the same as the -l flag for kubectl")
+	lintCmd.Flags().StringSliceVar(&options.Kinds, "kind", nil, "Kinds of resources to lint (default all kinds that can be listed)")
+	lintCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace to search for console sources (default all namespaces)")
+	lintCmd.Flags().BoolVarP(&options.AllNamespaces, "all-namespaces", "A", false, "Search for console sources in all namespaces, even when the config file lists namespaces")
+	lintCmd.Flags().StringVarP(&options.FromFile, "from-file", "f", "", "Path to a manifest to lint instead of the console sources in the cluster, - reads from stdin")
+	lintCmd.Flags().StringVarP(&lintOutput, "output", "o", "text", "Output format. One of: text, json, yaml")
+	lintCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(console.LintFormats, cobra.ShellCompDirectiveNoFileComp))
+}
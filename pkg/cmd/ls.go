@@ -33,6 +33,10 @@ kubeconsole ls --everyone --all-environment`,
 		console.List(K8sClient, environments, everyone, MachineID)
 	},
 	Args: func(cmd *cobra.Command, args []string) error {
+		if KubeconfigErr != nil {
+			return KubeconfigErr
+		}
+
 		for _, environment := range args {
 			// If no context with the specified name is found
 			if K8sClient.Contexts[environment] == nil {
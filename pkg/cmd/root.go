@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -25,19 +26,43 @@ var (
 	Verbose bool
 	// K8sClient is a instance of K8s that holds common kubernetes objects
 	K8sClient *k8s.K8s
+	// KubeconfigErr holds the error from loading the kubeconfig, if any
+	KubeconfigErr error
 	// MachineID is used to match console pods to this machine
 	MachineID string
 	options   console.Options
+	// runAsRoot is the deprecated --root flag, replaced by --security-profile root
+	runAsRoot bool
 )
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "kubeconsole [environment]",
-	Short: "kubeconsole is used to create a temporary pod based on a deployments specification",
+	Short: "kubeconsole is used to create a temporary pod based on the pod template of a deployment or another workload",
 	Example: `# Select a deployment in the production environment
 kubeconsole production
 # Run a custom command instead of the command specified in the deployment
-kubeconsole production -- /bin/bash`,
+kubeconsole production -- /bin/bash
+# Run the sandbox preset declared in the kubeconsole.presets annotation of the deployment
+kubeconsole production --preset sandbox
+# Only look for consoles defined as StatefulSets or CronJobs
+kubeconsole production --kind statefulset,cronjob
+# Use a deployment defined in a local file instead of one in the cluster
+kubeconsole production --from-file console.yaml
+kustomize build overlays/production | kubeconsole production --from-file -
+# Only search for consoles in the billing namespace
+kubeconsole production -n billing
+# Start bash, or sh if bash isn't available in the image
+kubeconsole production --shell
+# Replace the args of the deployment's command
+kubeconsole production --args=console --args=--sandbox
+# Print the pod that would be created after it has been validated by the cluster
+kubeconsole production --dry-run=server -o yaml
+# Override fields of the pod with a strategic merge patch
+kubeconsole production --overrides '{"spec":{"nodeSelector":{"pool":"consoles"}}}'
+kubeconsole production --overrides @overrides.yaml
+# Run with additional environment variables
+kubeconsole production --env LOG_LEVEL=debug --env-file .env --unset-env DATABASE_URL`,
 	Run: func(cmd *cobra.Command, args []string) {
 		K8sClient.SelectContext(args[0])
 
@@ -50,6 +75,10 @@ kubeconsole production -- /bin/bash`,
 		console.Start(K8sClient, options)
 	},
 	Args: func(cmd *cobra.Command, args []string) error {
+		if KubeconfigErr != nil {
+			return KubeconfigErr
+		}
+
 		argLength := len(args)
 		if argLength < 1 {
 			return errors.New("requires a environment argument")
@@ -60,14 +89,56 @@ kubeconsole production -- /bin/bash`,
 			return fmt.Errorf("invalid environment specified: %s, available environments are: %v", args[0], strings.Join(K8sClient.ContextNames(), ", "))
 		}
 
-		// If there is a second argument that's not dashes then we assign it to DeploymentName
+		if options.DryRun != "" && !slices.Contains(console.DryRunStrategies, options.DryRun) {
+			return fmt.Errorf("invalid dry-run value: %s, valid values are: %s", options.DryRun, strings.Join(console.DryRunStrategies, ", "))
+		}
+
+		if !slices.Contains(console.OutputFormats, options.Output) {
+			return fmt.Errorf("invalid output format: %s, valid formats are: %s", options.Output, strings.Join(console.OutputFormats, ", "))
+		}
+
+		if runAsRoot {
+			options.SecurityProfile = console.SecurityProfileRoot
+		}
+
+		if options.SecurityProfile != "" && !slices.Contains(console.SecurityProfiles, options.SecurityProfile) {
+			return fmt.Errorf("invalid security profile: %s, valid profiles are: %s", options.SecurityProfile, strings.Join(console.SecurityProfiles, ", "))
+		}
+
+		if !slices.Contains(console.ClaimInUseStrategies, options.ClaimInUse) {
+			return fmt.Errorf("invalid claim-in-use: %s, valid values are: %s", options.ClaimInUse, strings.Join(console.ClaimInUseStrategies, ", "))
+		}
+
+		if !slices.Contains(console.OverrideTypes, options.OverridesType) {
+			return fmt.Errorf("invalid overrides type: %s, valid types are: %s", options.OverridesType, strings.Join(console.OverrideTypes, ", "))
+		}
+
+		for _, kind := range options.Kinds {
+			if !K8sClient.HasSourceKind(kind) {
+				return fmt.Errorf("invalid kind: %s, valid kinds are: %s", kind, strings.Join(K8sClient.SourceKinds(), ", "))
+			}
+		}
+
+		if options.Shell != "" && (cmd.ArgsLenAtDash() >= 0 || options.Entrypoint != "" || cmd.Flags().Changed("args")) {
+			return errors.New("--shell can not be combined with --entrypoint, --args or a command")
+		}
+
+		if options.Preset != "" && (cmd.ArgsLenAtDash() >= 0 || options.Entrypoint != "" || options.Shell != "") {
+			return errors.New("--preset can not be combined with --shell, --entrypoint or a command")
+		}
+
+		if options.Entrypoint != "" && cmd.ArgsLenAtDash() >= 0 {
+			return errors.New("--entrypoint can not be combined with a command, use --args to pass arguments to it")
+		}
+
+		// If there is a second argument that's not dashes then we assign it to SourceName
 		if argLength > 1 && cmd.ArgsLenAtDash() != 1 {
-			options.DeploymentName = args[1]
+			options.SourceName = args[1]
 		} else {
-			// Otherwise we set DeploymentName to the current path
+			// Otherwise we set SourceName to the current path
 			path, err := os.Getwd()
 			if err == nil {
-				options.DeploymentName = filepath.Base(path)
+				options.SourceName = filepath.Base(path)
 			}
 		}
 
@@ -78,10 +149,10 @@ kubeconsole production -- /bin/bash`,
 		// Completing context names
 		case 0:
 			return K8sClient.ContextNamesWithPrefix(toComplete), cobra.ShellCompDirectiveNoFileComp
-		// Completing deployment names
+		// Completing source names
 		case 1:
 			K8sClient.SelectContext(args[0])
-			return K8sClient.DeploymentNamesWithPrefix(toComplete, options.LabelSelector), cobra.ShellCompDirectiveNoFileComp
+			return K8sClient.SourceNamesWithPrefix(toComplete, options.LabelSelector, options.Kinds, options.SearchNamespaces()), cobra.ShellCompDirectiveNoFileComp
 		default:
 			return nil, cobra.ShellCompDirectiveNoFileComp
 		}
@@ -103,17 +174,75 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&Kubeconfig, "kubeconfig", "", "kubeconfig file (default $HOME/.kube/config)")
 	rootCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "Enable verbose")
 
-	rootCmd.Flags().StringVarP(&options.LabelSelector, "selector", "l", "process=console", "Label selector used to filter the deployments, works the same as the -l flag for kubectl")
+	rootCmd.Flags().StringVarP(&options.LabelSelector, "selector", "l", "process=console", "Label selector used to filter the console sources, works the same as the -l flag for kubectl")
 	rootCmd.Flags().DurationVar(&options.Timeout, "timeout", 15*time.Minute, "Time that the pod should live after the heartbeat has stopped. For example 15m, 24h")
 	rootCmd.Flags().StringVar(&options.ContainerName, "container", "", "Container name. If omitted, use the kubectl.kubernetes.io/default-container annotation for selecting the container to be attached or the first container in the pod will be chosen")
 	rootCmd.Flags().StringVar(&options.Limits, "limits", "", "The resource requirement limits for this container. For example, 'cpu=200m,memory=512Mi'. The specified limits will also be set as requests")
 	rootCmd.Flags().StringVar(&options.Image, "image", "", "The image for the container to run. Replaces the image specified in the deployment")
 	rootCmd.Flags().BoolVarP(&options.NoRm, "no-rm", "", false, "Do not remove pod when detaching")
-	rootCmd.Flags().BoolVarP(&options.RunAsRoot, "root", "", false, "Run pod as root")
+	rootCmd.Flags().StringVar(&options.SecurityProfile, "security-profile", "", "Security context applied to the pod and containers. One of: restricted, baseline, root, debug (root with SYS_PTRACE, NET_ADMIN and NET_RAW). Checked against the Pod Security Admission level enforced in the namespace. Defaults to the security context of the pod template")
+	rootCmd.Flags().BoolVar(&runAsRoot, "root", false, "Run pod as root")
+	rootCmd.Flags().MarkDeprecated("root", "use --security-profile root instead")
+	rootCmd.MarkFlagsMutuallyExclusive("root", "security-profile")
+	rootCmd.Flags().DurationVar(&options.IdleTimeout, "idle-timeout", 0, "End the session and delete the pod when no input has been received for this long, 0 disables it. Can also be set with idle-timeout in the config file, and overridden with the kubeconsole.idle-timeout annotation on the deployment. For example 30m, 2h")
+
+	rootCmd.Flags().StringVar(&options.DryRun, "dry-run", "", "Print the pod instead of creating it. Must be \"client\" or \"server\", with server the pod is submitted to the cluster without being persisted so that admission errors surface")
+	rootCmd.Flags().Lookup("dry-run").NoOptDefVal = console.DryRunClient
+	rootCmd.Flags().StringVarP(&options.Output, "output", "o", "yaml", "Output format used with --dry-run. One of: yaml, json")
+	rootCmd.Flags().BoolVar(&options.SkipInit, "skip-init", false, "Do not run the init containers of the pod template. Native sidecars are kept, use --only-container to remove them")
+	rootCmd.Flags().StringSliceVar(&options.InitContainers, "init-containers", nil, "Only run these init containers of the pod template. Defaults to the kubeconsole.init-containers annotation on the deployment, or all init containers. Native sidecars are always kept")
+	rootCmd.MarkFlagsMutuallyExclusive("skip-init", "init-containers")
+	rootCmd.Flags().BoolVar(&options.OnlyContainer, "only-container", false, "Only run the console container, removing the other containers and sidecars of the pod template. Can also be enabled with the kubeconsole.only-container annotation on the deployment")
+	rootCmd.Flags().StringSliceVar(&options.KeepContainers, "keep-containers", nil, "Containers to keep when using --only-container, for example a database proxy. Defaults to the kubeconsole.keep-containers annotation on the deployment")
+	rootCmd.Flags().BoolVar(&options.NoMesh, "no-mesh", false, "Disable Istio and Linkerd sidecar proxy injection for the console pod")
+	rootCmd.Flags().StringVar(&options.Reason, "reason", "", "Why the console is run, for example a ticket ID. Stored on the pod and shown by ls, prompted for in protected environments. Must match reason-pattern from the config file if set")
+	rootCmd.Flags().BoolVar(&options.SkipPreflight, "skip-preflight", false, "Do not check that you have the permissions needed to run the console before creating the pod")
+	rootCmd.Flags().StringVar(&options.Overrides, "overrides", "", "An inline JSON or YAML override for the pod, or a path to a file prefixed with @. Applied after all other flags")
+	rootCmd.Flags().StringVar(&options.OverridesType, "overrides-type", "strategic", "The method used to apply the overrides. One of: strategic, merge, json")
+	rootCmd.Flags().StringArrayVarP(&options.Env, "env", "e", nil, "Environment variable to set in the container, for example KEY=VALUE. KEY- unsets the variable. Can be repeated")
+	rootCmd.Flags().StringSliceVar(&options.EnvFiles, "env-file", nil, "Path to a file with KEY=VALUE lines to set in the container. Can be repeated")
+	rootCmd.Flags().StringSliceVar(&options.EnvFromSecrets, "env-from-secret", nil, "Name of a secret whose keys are set as environment variables in the container. Can be repeated")
+	rootCmd.Flags().StringSliceVar(&options.UnsetEnv, "unset-env", nil, "Environment variable to remove from the container. Can be repeated")
+	rootCmd.Flags().StringVar(&options.Entrypoint, "entrypoint", "", "Replace the command of the container, the args of the container are kept unless --args is specified")
+	rootCmd.Flags().StringArrayVar(&options.Args, "args", nil, "Replace the args of the container. Can be repeated")
+	rootCmd.Flags().StringVarP(&options.WorkingDir, "workdir", "w", "", "Working directory inside the container")
+	rootCmd.Flags().StringVar(&options.Shell, "shell", "", "Start a shell instead of the command of the container, falls back to sh if the shell isn't available in the image")
+	rootCmd.Flags().Lookup("shell").NoOptDefVal = "bash"
+	rootCmd.Flags().StringVar(&options.Preset, "preset", "", "Run a command preset declared in the kubeconsole.presets annotation of the deployment")
+	rootCmd.RegisterFlagCompletionFunc("preset", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 || K8sClient.Contexts[args[0]] == nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		sourceName := ""
+		if len(args) > 1 {
+			sourceName = args[1]
+		}
+
+		K8sClient.SelectContext(args[0])
+		sources, _ := K8sClient.Sources(options.LabelSelector, options.Kinds, options.SearchNamespaces())
+		return console.PresetNamesWithPrefix(sources, sourceName, toComplete), cobra.ShellCompDirectiveNoFileComp
+	})
+	rootCmd.Flags().StringSliceVar(&options.Kinds, "kind", nil, "Kinds of resources to use as console sources. One or more of: cronjob, daemonset, deployment, job, podtemplate, statefulset or a custom source from the config file (default all kinds that can be listed)")
+	rootCmd.Flags().StringVarP(&options.FromFile, "from-file", "f", "", "Path to a manifest with the deployments, pod templates or other workloads to use as console sources instead of the ones in the cluster, - reads from stdin")
+	rootCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Namespace to search for console sources, also used for the sources read with --from-file that don't specify one (default all namespaces or the namespaces in the config file, falling back to the namespace of the context if listing all namespaces is forbidden)")
+	rootCmd.Flags().BoolVarP(&options.AllNamespaces, "all-namespaces", "A", false, "Search for console sources in all namespaces, even when the config file lists namespaces")
+	rootCmd.MarkFlagsMutuallyExclusive("namespace", "all-namespaces")
+	rootCmd.Flags().StringVar(&options.ClaimInUse, "claim-in-use", console.ClaimInUseAsk, "What to do when a ReadWriteOnce claim mounted by the console is in use on another node. One of: ask, node (run on the node holding the claim), emptydir, abort")
+	rootCmd.Flags().StringToStringVar(&options.VolumeClaims, "volume-claim", nil, "Mount an existing claim for a volume claim template of a StatefulSet, for example data=data-db-0. Templates without a claim are replaced with an emptyDir")
+	rootCmd.RegisterFlagCompletionFunc("kind", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return K8sClient.SourceKinds(), cobra.ShellCompDirectiveNoFileComp
+	})
+	rootCmd.RegisterFlagCompletionFunc("dry-run", cobra.FixedCompletions(console.DryRunStrategies, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(console.OutputFormats, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("security-profile", cobra.FixedCompletions(console.SecurityProfiles, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("claim-in-use", cobra.FixedCompletions(console.ClaimInUseStrategies, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("overrides-type", cobra.FixedCompletions(console.OverrideTypes, cobra.ShellCompDirectiveNoFileComp))
 
 	viper.BindPFlag("kubeconfig", rootCmd.PersistentFlags().Lookup("kubeconfig"))
 	viper.BindPFlag("selector", rootCmd.PersistentFlags().Lookup("selector"))
 	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("idle-timeout", rootCmd.Flags().Lookup("idle-timeout"))
 }
 
 func initConfig() {
@@ -151,5 +280,22 @@ func initConfig() {
 		panic(err)
 	}
 
-	K8sClient = k8s.NewK8s(Kubeconfig)
+	K8sClient, KubeconfigErr = k8s.NewK8s(Kubeconfig)
+	options.Namespaces = viper.GetStringSlice("namespaces")
+	// The flag takes precedence over the config file since it's bound to viper
+	options.IdleTimeout = viper.GetDuration("idle-timeout")
+	options.ProtectedEnvs = viper.GetStringSlice("protected-environments")
+	options.ProtectUnknown = viper.GetBool("protect-unreadable-namespaces")
+	options.ReasonPattern = viper.GetString("reason-pattern")
+
+	if err := viper.UnmarshalKey("sources", &K8sClient.CustomSources); err != nil {
+		fmt.Println("Invalid sources in config file:", err)
+		os.Exit(1)
+	}
+	for _, customSource := range K8sClient.CustomSources {
+		if err := customSource.Validate(); err != nil {
+			fmt.Println("Invalid sources in config file:", err)
+			os.Exit(1)
+		}
+	}
 }
@@ -0,0 +1,174 @@
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// requireApprovalAnnotation makes consoles of a deployment wait for approval by another user when set to true
+	requireApprovalAnnotation = "kubeconsole.require-approval"
+	// approvalGate is the scheduling gate that keeps the pod from being scheduled until it's approved
+	approvalGate = "kubeconsole/approval"
+	// creatorUserAnnotation holds the user the cluster authenticated the creator as
+	creatorUserAnnotation = "kubeconsole.creator.user"
+	approvedByAnnotation  = "kubeconsole.approved-by"
+	approvedAtAnnotation  = "kubeconsole.approved-at"
+	deniedByAnnotation    = "kubeconsole.denied-by"
+)
+
+// deniedError is returned when waiting for a pod that was denied
+type deniedError struct {
+	by string
+}
+
+func (e *deniedError) Error() string {
+	return fmt.Sprintf("the console was denied by %s", e.by)
+}
+
+// requiresApproval returns true if the consoles of the source has to be approved by another user
+func requiresApproval(source *k8s.Source) bool {
+	required, _ := strconv.ParseBool(source.Annotations[requireApprovalAnnotation])
+	return required
+}
+
+// applyApproval gates the scheduling of the pod until it's approved and records who created it,
+// so that the creator can't approve it
+func applyApproval(k8sClient *k8s.K8s, pod *apiv1.Pod) error {
+	username, err := k8sClient.Username()
+	if err != nil {
+		return fmt.Errorf("unable to determine the user: %w", err)
+	}
+
+	pod.Annotations[creatorUserAnnotation] = username
+	pod.Spec.SchedulingGates = append(pod.Spec.SchedulingGates, apiv1.PodSchedulingGate{Name: approvalGate})
+
+	return nil
+}
+
+func awaitingApproval(pod *apiv1.Pod) bool {
+	return slices.ContainsFunc(pod.Spec.SchedulingGates, func(gate apiv1.PodSchedulingGate) bool {
+		return gate.Name == approvalGate
+	})
+}
+
+// reviewablePod returns the pod if it's awaiting approval and wasn't created by the current user,
+// along with the current user
+func reviewablePod(k8sClient *k8s.K8s, namespace string, name string) (*apiv1.Pod, string, error) {
+	pod, err := k8sClient.Clientset.CoreV1().Pods(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !awaitingApproval(pod) {
+		return nil, "", fmt.Errorf("the pod %s/%s is not awaiting approval", namespace, name)
+	}
+
+	username, err := k8sClient.Username()
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to determine the user: %w", err)
+	}
+
+	if username == pod.Annotations[creatorUserAnnotation] {
+		return nil, "", fmt.Errorf("the pod %s/%s was created by you, it has to be reviewed by someone else", namespace, name)
+	}
+
+	return pod, username, nil
+}
+
+// Approve removes the approval gate from the pod so that it's scheduled, the approver is recorded on the pod.
+// A JSON patch is used so that it doesn't conflict with the heartbeat, the gate is tested so that the
+// patch fails if the gates changed since the pod was read.
+func Approve(k8sClient *k8s.K8s, namespace string, name string) error {
+	pod, username, err := reviewablePod(k8sClient, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	gate := slices.IndexFunc(pod.Spec.SchedulingGates, func(gate apiv1.PodSchedulingGate) bool {
+		return gate.Name == approvalGate
+	})
+	gatePath := fmt.Sprintf("/spec/schedulingGates/%d", gate)
+	patch, err := json.Marshal([]map[string]string{
+		{"op": "test", "path": gatePath + "/name", "value": approvalGate},
+		{"op": "remove", "path": gatePath},
+		{"op": "add", "path": annotationPath(approvedByAnnotation), "value": username},
+		{"op": "add", "path": annotationPath(approvedAtAnnotation), "value": time.Now().Format(time.RFC3339)},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := k8sClient.Clientset.CoreV1().Pods(namespace).Patch(context.TODO(), name, types.JSONPatchType, patch, metav1.PatchOptions{}); err != nil {
+		return err
+	}
+
+	fmt.Printf("Approved pod %s/%s created by %s\n", namespace, name, pod.Annotations[creatorUserAnnotation])
+
+	return nil
+}
+
+// Deny records who denied the pod and deletes it
+func Deny(k8sClient *k8s.K8s, namespace string, name string) error {
+	pod, username, err := reviewablePod(k8sClient, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{deniedByAnnotation: username},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	podsClient := k8sClient.Clientset.CoreV1().Pods(namespace)
+	if _, err := podsClient.Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return err
+	}
+
+	if err := podsClient.Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil {
+		return err
+	}
+
+	fmt.Printf("Denied pod %s/%s created by %s\n", namespace, name, pod.Annotations[creatorUserAnnotation])
+
+	return nil
+}
+
+// annotationPath returns the JSON pointer to an annotation
+func annotationPath(annotation string) string {
+	return "/metadata/annotations/" + strings.ReplaceAll(strings.ReplaceAll(annotation, "~", "~0"), "/", "~1")
+}
+
+// PodNamesAwaitingApprovalWithPrefix returns the names of the console pods in the namespace that are
+// awaiting approval and begins with the passed prefix
+func PodNamesAwaitingApprovalWithPrefix(k8sClient *k8s.K8s, namespace string, prefix string) []string {
+	listOptions := metav1.ListOptions{LabelSelector: fields.OneTermEqualSelector("kubeconsole.garbagecollect", "true").String()}
+	pods, err := k8sClient.Clientset.CoreV1().Pods(namespace).List(context.TODO(), listOptions)
+	if err != nil {
+		return nil
+	}
+
+	names := []string{}
+	for i := range pods.Items {
+		if awaitingApproval(&pods.Items[i]) && strings.HasPrefix(pods.Items[i].Name, prefix) {
+			names = append(names, pods.Items[i].Name)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,201 @@
+package console
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ClaimInUseAsk prompts for how to handle claims that are in use on another node
+	ClaimInUseAsk = "ask"
+	// ClaimInUseNode schedules the console onto the node holding the claims
+	ClaimInUseNode = "node"
+	// ClaimInUseEmptyDir replaces the volumes of the claims with an emptyDir
+	ClaimInUseEmptyDir = "emptydir"
+	// ClaimInUseAbort exits without creating the console
+	ClaimInUseAbort = "abort"
+)
+
+// ClaimInUseStrategies are the valid values for --claim-in-use
+var ClaimInUseStrategies = []string{ClaimInUseAsk, ClaimInUseNode, ClaimInUseEmptyDir, ClaimInUseAbort}
+
+// volumeClaimVolumes returns the volumes for the volume claim templates of a StatefulSet.
+// Claims passed with --volume-claim are mounted, the others are replaced with an emptyDir.
+func volumeClaimVolumes(source *k8s.Source, volumeClaims map[string]string) []apiv1.Volume {
+	volumes := make([]apiv1.Volume, len(source.VolumeClaimTemplates))
+
+	for i, template := range source.VolumeClaimTemplates {
+		volumes[i] = apiv1.Volume{Name: template.Name}
+
+		if claimName, ok := volumeClaims[template.Name]; ok {
+			volumes[i].PersistentVolumeClaim = &apiv1.PersistentVolumeClaimVolumeSource{ClaimName: claimName}
+		} else {
+			fmt.Fprintf(os.Stderr, "Using an emptyDir for the volume claim template %s, use --volume-claim %s=<claim> to mount an existing claim\n", template.Name, template.Name)
+			volumes[i].EmptyDir = &apiv1.EmptyDirVolumeSource{}
+		}
+	}
+
+	return volumes
+}
+
+// claimInUse is a ReadWriteOnce claim mounted by a volume of the console pod that is already
+// used by pods on a node, the console pod can only mount it if it runs on the same node
+type claimInUse struct {
+	Volume string
+	Claim  string
+	Node   string
+	// ExclusiveToPod is set for ReadWriteOncePod claims that can't be shared with the console at all
+	ExclusiveToPod bool
+}
+
+// findClaimsInUse returns the ReadWriteOnce claims mounted by the pod that are in use by other pods.
+// Claims or pods that can't be read are skipped, the pod will then wait until the claim is released.
+func findClaimsInUse(k8sClient *k8s.K8s, pod *apiv1.Pod) []claimInUse {
+	var claims []claimInUse
+	var pods *apiv1.PodList
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil || volume.PersistentVolumeClaim.ReadOnly {
+			continue
+		}
+
+		claimName := volume.PersistentVolumeClaim.ClaimName
+		claim, err := k8sClient.Clientset.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(context.TODO(), claimName, metav1.GetOptions{})
+		if err != nil || claim.Status.Phase != apiv1.ClaimBound {
+			continue
+		}
+
+		accessModes := claim.Status.AccessModes
+		exclusiveToPod := slices.Contains(accessModes, apiv1.ReadWriteOncePod)
+		if !exclusiveToPod && (!slices.Contains(accessModes, apiv1.ReadWriteOnce) || slices.Contains(accessModes, apiv1.ReadWriteMany)) {
+			continue
+		}
+
+		if pods == nil {
+			pods, err = k8sClient.Clientset.CoreV1().Pods(pod.Namespace).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				return claims
+			}
+		}
+
+		if node, ok := claimNode(pods.Items, claimName); ok {
+			claims = append(claims, claimInUse{Volume: volume.Name, Claim: claimName, Node: node, ExclusiveToPod: exclusiveToPod})
+		}
+	}
+
+	return claims
+}
+
+// claimNode returns the node of a scheduled pod that hasn't completed which mounts the claim
+func claimNode(pods []apiv1.Pod, claimName string) (string, bool) {
+	for _, p := range pods {
+		if p.Spec.NodeName == "" || p.Status.Phase == apiv1.PodSucceeded || p.Status.Phase == apiv1.PodFailed {
+			continue
+		}
+
+		for _, volume := range p.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claimName {
+				return p.Spec.NodeName, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// applyClaimsInUse makes sure the pod doesn't stay pending forever waiting for ReadWriteOnce claims
+// in use on another node, either by scheduling it onto that node, replacing the volumes with an
+// emptyDir or aborting
+func applyClaimsInUse(k8sClient *k8s.K8s, pod *apiv1.Pod, options Options) {
+	claims := findClaimsInUse(k8sClient, pod)
+	if len(claims) == 0 {
+		return
+	}
+
+	// Scheduling onto the node is only possible if all the claims are on the same node and can be shared
+	nodes := map[string]bool{}
+	shareable := true
+	for _, claim := range claims {
+		nodes[claim.Node] = true
+		shareable = shareable && !claim.ExclusiveToPod
+		fmt.Fprintf(os.Stderr, "The ReadWriteOnce claim %s of the volume %s is in use on the node %s\n", claim.Claim, claim.Volume, claim.Node)
+	}
+	schedulable := shareable && len(nodes) == 1
+
+	strategy := options.ClaimInUse
+	if strategy == ClaimInUseAsk || strategy == "" {
+		strategy = selectClaimInUseStrategy(claims[0].Node, schedulable)
+	}
+
+	switch strategy {
+	case ClaimInUseNode:
+		if !schedulable {
+			fmt.Fprintln(os.Stderr, "The console can't be scheduled onto the node holding the claims, they are exclusive to a pod or in use on different nodes")
+			os.Exit(1)
+		}
+		scheduleOntoNode(pod, claims[0].Node)
+	case ClaimInUseEmptyDir:
+		for _, claim := range claims {
+			i := slices.IndexFunc(pod.Spec.Volumes, func(volume apiv1.Volume) bool { return volume.Name == claim.Volume })
+			pod.Spec.Volumes[i].VolumeSource = apiv1.VolumeSource{EmptyDir: &apiv1.EmptyDirVolumeSource{}}
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "A ReadWriteOnce claim can only be mounted by pods on one node at a time, the console would stay pending until the claim is released")
+		os.Exit(1)
+	}
+}
+
+func selectClaimInUseStrategy(node string, schedulable bool) string {
+	strategies := []string{}
+	labels := []string{}
+	if schedulable {
+		strategies = append(strategies, ClaimInUseNode)
+		labels = append(labels, fmt.Sprintf("Run the console on the node %s", node))
+	}
+	strategies = append(strategies, ClaimInUseEmptyDir, ClaimInUseAbort)
+	labels = append(labels, "Replace the volumes with an emptyDir", "Abort")
+
+	selected := 0
+	prompt := &survey.Select{
+		Message: "How do you want to handle the claims in use?",
+		Options: labels,
+	}
+	err := survey.AskOne(prompt, &selected)
+	if err == terminal.InterruptErr {
+		fmt.Println("Cancelled")
+		os.Exit(0)
+	} else if err != nil {
+		panic(err)
+	}
+
+	return strategies[selected]
+}
+
+// scheduleOntoNode requires the pod to be scheduled onto the node, replacing any other node affinity
+// since it could otherwise make the pod unschedulable. The scheduler is still used so that taints
+// and resources are taken into account.
+func scheduleOntoNode(pod *apiv1.Pod, node string) {
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &apiv1.Affinity{}
+	}
+	pod.Spec.NodeSelector = nil
+	pod.Spec.Affinity.NodeAffinity = &apiv1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &apiv1.NodeSelector{
+			NodeSelectorTerms: []apiv1.NodeSelectorTerm{{
+				MatchFields: []apiv1.NodeSelectorRequirement{{
+					Key:      "metadata.name",
+					Operator: apiv1.NodeSelectorOpIn,
+					Values:   []string{node},
+				}},
+			}},
+		},
+	}
+}
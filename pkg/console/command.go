@@ -0,0 +1,51 @@
+package console
+
+import (
+	"fmt"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// fallbackShell is used when the shell passed to --shell isn't available in the image
+const fallbackShell = "sh"
+
+// applyCommand sets the command, args and working directory of the container.
+// A command passed after -- replaces both the command and the args of the container
+// while --entrypoint and --args can be used to replace them individually.
+func applyCommand(container *apiv1.Container, options Options) {
+	switch {
+	case options.Shell != "":
+		container.Command = shellCommand(options.Shell)
+		container.Args = nil
+	case len(options.Command) > 0:
+		container.Command = options.Command
+		container.Args = nil
+	case options.Entrypoint != "":
+		container.Command = []string{options.Entrypoint}
+	}
+
+	if options.Args != nil {
+		container.Args = options.Args
+	}
+
+	if options.WorkingDir != "" {
+		container.WorkingDir = options.WorkingDir
+	}
+}
+
+// shellCommand returns a command that starts the first shell in the chain that exists in the image
+func shellCommand(shell string) []string {
+	shells := []string{shell}
+	if shell != fallbackShell {
+		shells = append(shells, fallbackShell)
+	}
+
+	script := make([]string, 0, len(shells))
+	for _, s := range shells {
+		script = append(script, fmt.Sprintf("command -v %[1]s >/dev/null 2>&1 && exec %[1]s", s))
+	}
+	script = append(script, fmt.Sprintf("echo 'No shell found, tried: %s' >&2; exit 127", strings.Join(shells, ", ")))
+
+	return []string{"/bin/sh", "-c", strings.Join(script, "; ")}
+}
@@ -8,6 +8,7 @@ import (
 	"math"
 	"os"
 	"os/user"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,7 +18,6 @@ import (
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/AlecAivazis/survey/v2/terminal"
 	"github.com/micke/kubeconsole/pkg/k8s"
-	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -25,6 +25,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
@@ -40,44 +41,88 @@ import (
 
 // Options defines how the console should be ran
 type Options struct {
-	LabelSelector  string
-	Timeout        time.Duration
-	Command        []string
-	ContainerName  string
-	Limits         string
-	Image          string
-	NoRm           bool
-	DeploymentName string
-	MachineID      string
-	RunAsRoot      bool
+	LabelSelector   string
+	Kinds           []string
+	FromFile        string
+	Namespace       string
+	AllNamespaces   bool
+	SkipPreflight   bool
+	SkipInit        bool
+	InitContainers  []string
+	OnlyContainer   bool
+	KeepContainers  []string
+	NoMesh          bool
+	Namespaces      []string
+	VolumeClaims    map[string]string
+	ClaimInUse      string
+	Timeout         time.Duration
+	Command         []string
+	Entrypoint      string
+	Args            []string
+	WorkingDir      string
+	Shell           string
+	Preset          string
+	ContainerName   string
+	Limits          string
+	Image           string
+	NoRm            bool
+	SourceName      string
+	MachineID       string
+	SecurityProfile string
+	ProtectedEnvs   []string
+	ProtectUnknown  bool
+	Reason          string
+	ReasonPattern   string
+	IdleTimeout     time.Duration
+	DryRun          string
+	Output          string
+	Overrides       string
+	OverridesType   string
+	Env             []string
+	EnvFiles        []string
+	EnvFromSecrets  []string
+	UnsetEnv        []string
 }
 
 var (
 	defaultAttachTimeout = 30 * time.Second
+	containerExitTimeout = 15 * time.Second
 	errInterrupted       = errors.New("interrupted")
 )
 
 // Start the console
 func Start(k8s *k8s.K8s, options Options) {
-	deployments := k8s.Deployments(options.LabelSelector)
+	sources := findSources(k8s, options)
 
-	if len(deployments) == 0 {
-		fmt.Fprintf(os.Stderr, "No mathing deployments found. label-selector is currently: %s\n", options.LabelSelector)
+	// The manifest was read from stdin so the terminal has to be opened for the prompts and the console
+	if options.FromFile == "-" {
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening the terminal: %s\n", err)
+			os.Exit(1)
+		}
+		os.Stdin = tty
+	}
+
+	if len(sources) == 0 {
+		fmt.Fprintf(os.Stderr, "No mathing console sources found. label-selector is currently: %s\n", options.LabelSelector)
 		os.Exit(1)
 	}
 
-	deployment := selectDeployment(deployments, options.DeploymentName)
+	source := selectSource(sources, options.SourceName)
+
+	// Use the command of a preset declared on the source
+	if command := selectPreset(source, options); command != nil {
+		options.Command = command
+	}
 
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 
-	podsClient := k8s.Clientset.CoreV1().Pods(deployment.Namespace)
-	pod := &apiv1.Pod{
-		Spec:       deployment.Spec.Template.Spec,
-		ObjectMeta: deployment.Spec.Template.ObjectMeta,
-	}
+	podsClient := k8s.Clientset.CoreV1().Pods(source.Namespace)
+	pod := podFromSource(source)
 	container, err := podcmd.FindOrDefaultContainerByName(pod, options.ContainerName, true, os.Stderr)
 	if err != nil {
 		panic(err)
@@ -89,22 +134,36 @@ func Start(k8s *k8s.K8s, options Options) {
 	if pod.Annotations == nil {
 		pod.Annotations = map[string]string{}
 	}
+	pod.Namespace = source.Namespace
 	pod.Labels["kubeconsole.garbagecollect"] = "true"
 	pod.Labels["kubeconsole.creator.machineid"] = options.MachineID
 	pod.Annotations["kubeconsole.creator.username"] = user.Username
 	pod.Annotations["kubeconsole.creator.name"] = user.Name
 	pod.Annotations["kubeconsole.heartbeat"] = time.Now().Format(time.RFC3339)
-	pod.Annotations["kubeconsole.timeout"] = strconv.Itoa(int(options.Timeout.Minutes()))
+	pod.Annotations[timeoutAnnotation] = strconv.Itoa(int(options.Timeout.Minutes()))
+	pod.Annotations[sourceAnnotation] = source.DisplayName()
 
 	pod.Spec.RestartPolicy = apiv1.RestartPolicyNever
+	pod.Spec.Volumes = append(pod.Spec.Volumes, volumeClaimVolumes(source, options.VolumeClaims)...)
 	container.TTY = true
 	container.Stdin = true
 
-	// Set command if one was provided
-	if len(options.Command) > 0 {
-		container.Command = options.Command
+	// Remove the init containers that shouldn't run before the console
+	if err := applyInitContainers(pod, source, options); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid init containers: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Remove the other containers and sidecars if only the console container should run
+	container, err = applyOnlyContainer(pod, container, source, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid containers: %s\n", err)
+		os.Exit(1)
 	}
 
+	// Set command, args and working directory if they were provided
+	applyCommand(container, options)
+
 	// Set default GenerateName if it's not already set
 	if pod.GenerateName == "" {
 		pod.GenerateName = "kubeconsole-"
@@ -122,19 +181,94 @@ func Start(k8s *k8s.K8s, options Options) {
 		container.Resources = resourceRequirements
 	}
 
-	// Set security context to run as root
-	if options.RunAsRoot {
-		runAsNonRoot  := false
-		runAsUser := int64(0)
-		pod.Spec.SecurityContext.RunAsNonRoot = &runAsNonRoot
-		pod.Spec.SecurityContext.RunAsUser = &runAsUser
+	// The namespace is read once for its Pod Security Admission level, protection and service mesh
+	// injection, namespace scoped users usually can't read it
+	namespace, err := k8s.Clientset.CoreV1().Namespaces().Get(context.TODO(), pod.Namespace, metav1.GetOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read the %s namespace, its annotations and Pod Security Admission level are not checked: %s\n", pod.Namespace, err)
+		namespace = nil
 	}
 
+	// Set the security context of the pod and containers for the profile
+	applySecurityProfile(pod, container, options.SecurityProfile)
+
 	// Set image if one was specified
 	if options.Image != "" {
 		container.Image = options.Image
 	}
 
+	// Set and unset environment variables
+	if err := applyEnv(container, options); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid environment: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Tell the app which environment it runs in and whether it's protected
+	protected := isProtected(k8s, source, namespace, options)
+	applyProtected(container, k8s.CurrentContext, protected)
+
+	// Disable service mesh injection or make the injected proxies start before the console
+	prepareMeshes(pod, namespace, options)
+
+	// Apply overrides on top of the pod, the container is looked up again since the pod is replaced
+	if options.Overrides != "" {
+		pod, err = applyOverrides(pod, options.Overrides, options.OverridesType)
+		if err == nil {
+			container, err = podcmd.FindOrDefaultContainerByName(pod, container.Name, true, nil)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid overrides: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Check the pod as it will be created, the overrides may have changed the security context
+	if err := validatePodSecurity(namespace, pod, options.SecurityProfile); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid security context: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Make sure the console is allowed by the policy of the source
+	enforcePolicy(k8s, pod, source)
+
+	// Keep the pod from being scheduled until another user has approved it
+	if requiresApproval(source) {
+		if err := applyApproval(k8s, pod); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to request approval: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Handle ReadWriteOnce claims that are in use on another node
+	applyClaimsInUse(k8s, pod, options)
+
+	// Require the name of protected environments to be typed before continuing, unless only printing the pod
+	if protected && options.DryRun == "" {
+		confirmProtected(k8s.CurrentContext)
+	}
+
+	// Record why the console is run, required in protected environments
+	if reason := selectReason(options, protected && options.DryRun == ""); reason != "" {
+		pod.Annotations[reasonAnnotation] = reason
+	}
+
+	// Print the pod instead of creating it
+	if options.DryRun != "" {
+		if err := dryRun(pod, podsClient, options, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Dry run failed: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Make sure the user is allowed to run the console before creating anything
+	if !options.SkipPreflight {
+		preflight(k8s, pod.Namespace, options)
+	}
+
+	// Record the session as events on the source
+	audit := newAuditor(k8s, source, user.Username, pod.Annotations[reasonAnnotation])
+
 	// Find existing pod if one exists
 	attachablePod := findRunningPod(pod, podsClient)
 
@@ -145,13 +279,33 @@ func Start(k8s *k8s.K8s, options Options) {
 			panic(err)
 		}
 		fmt.Printf("Created pod %s/%s\n", attachablePod.Namespace, attachablePod.Name)
+		audit.started(attachablePod, container)
+	}
+
+	if awaitingApproval(attachablePod) {
+		fmt.Printf("Waiting for approval, someone else can approve it with: kubeconsole approve %s %s -n %s\n", k8s.CurrentContext, attachablePod.Name, attachablePod.Namespace)
 	}
 
+	for _, m := range injectedMeshes(attachablePod) {
+		fmt.Printf("The %s proxy has been injected, waiting for it to be ready\n", m.Name)
+	}
+
+	// The session is ended by the idle timeout or when detaching, whichever comes first
+	var endOnce sync.Once
+	endSession := func() {
+		endOnce.Do(func() {
+			if deletePod(attachablePod, podsClient) {
+				audit.ended(attachablePod)
+			}
+		})
+	}
 	if !options.NoRm {
-		defer deletePod(attachablePod, podsClient)
+		defer endSession()
 	}
 	go watchPodEvents(attachablePod, k8s.Clientset)
-	scheduleHeartbeat(attachablePod, podsClient)
+	scheduleHeartbeat(attachablePod, podsClient, func() {
+		audit.extendedBy(attachablePod, options.Timeout)
+	})
 
 	attachOpts := &attach.AttachOptions{
 		StreamOptions: exec.StreamOptions{
@@ -170,17 +324,44 @@ func Start(k8s *k8s.K8s, options Options) {
 		Config:        k8s.RestConfig,
 		AttachFunc:    attach.DefaultAttachFunc,
 	}
+	attachOpts.Attach = &auditRemoteAttach{RemoteAttach: attachOpts.Attach, auditor: audit, pod: attachablePod}
+
+	// End the session and delete the pod when no input has been received for a while
+	if timeout := idleTimeout(source.Annotations, options.IdleTimeout); timeout > 0 {
+		monitor := newIdleMonitor(
+			timeout,
+			func(remaining time.Duration) {
+				fmt.Fprintf(os.Stderr, "\r\nNo input received, the session will be terminated in %s\r\n", remaining.Round(time.Second))
+			},
+			func() {
+				fmt.Fprintf(os.Stderr, "\r\nSession has been idle for %s, terminating\r\n", timeout)
+				endSession()
+			},
+		)
+		attachOpts.Attach = &idleRemoteAttach{RemoteAttach: attachOpts.Attach, monitor: monitor}
+	}
 
 	err = handleAttachPod(podsClient, attachablePod, attachOpts)
-	if err != nil && err != errInterrupted {
+	var denied *deniedError
+	if errors.As(err, &denied) {
+		fmt.Fprintf(os.Stderr, "%s\n", denied)
+		return
+	} else if err != nil && err != errInterrupted {
 		panic(err)
 	}
+
+	// The pod is kept so the session ends when the console exits, the proxies then have to be
+	// stopped for the pod to complete. Nothing is done if the console is still running.
+	if options.NoRm && waitForContainerExit(podsClient, attachablePod, container.Name) {
+		audit.ended(attachablePod)
+		stopMeshProxies(k8s, attachablePod)
+	}
 }
 
 // List lists all running console pods
 func List(k8s *k8s.K8s, environments []string, everyone bool, machineID string) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "ENVIRONMENT\tNAME\tNAMESPACE\tCREATOR\tAGE\tIMAGE\tLABELS")
+	fmt.Fprintln(w, "ENVIRONMENT\tNAME\tNAMESPACE\tCREATOR\tAGE\tIMAGE\tREASON\tLABELS")
 
 	selectors := map[string]string{
 		"kubeconsole.garbagecollect": "true",
@@ -198,33 +379,42 @@ func List(k8s *k8s.K8s, environments []string, everyone bool, machineID string)
 		environmentWriters[environment] = bufio.NewWriter(w)
 
 		go func(environment string) {
-			k8s.SelectContext(environment)
+			defer wg.Done()
+
+			// Each environment gets its own client since the environments are listed concurrently
+			environmentK8s, err := k8s.ForContext(environment)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error configuring client for %s: %s\n", environment, err)
+				return
+			}
 
-			podsClient := k8s.Clientset.CoreV1().Pods("")
-			pods, err := podsClient.List(
-				context.TODO(),
-				metav1.ListOptions{LabelSelector: fields.SelectorFromSet(selectors).String()},
-			)
+			listOptions := metav1.ListOptions{LabelSelector: fields.SelectorFromSet(selectors).String()}
+			pods, err := environmentK8s.Clientset.CoreV1().Pods("").List(context.TODO(), listOptions)
+
+			// Fall back to the namespace of the context if listing pods in all namespaces is forbidden
+			if apierrors.IsForbidden(err) {
+				pods, err = environmentK8s.Clientset.CoreV1().Pods(environmentK8s.ContextNamespace()).List(context.TODO(), listOptions)
+			}
 
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error fetching pods for %s: %s\n", environment, err)
+				return
 			}
 
 			for _, p := range pods.Items {
 				fmt.Fprintf(
 					environmentWriters[environment],
-					"%s\t%s\t%s\t%s\t%s\t%v\t%v\n",
+					"%s\t%s\t%s\t%s\t%s\t%v\t%s\t%v\n",
 					environment,
 					p.Name,
 					p.Namespace,
 					p.Annotations["kubeconsole.creator.name"],
 					formatAge(p.CreationTimestamp.Time),
 					p.Spec.Containers[0].Image,
+					p.Annotations[reasonAnnotation],
 					formatLabels(p.Labels),
 				)
 			}
-
-			wg.Done()
 		}(environment)
 	}
 
@@ -263,39 +453,93 @@ func formatLabels(labels map[string]string) string {
 	return strings.Join(formattedLabels, " ")
 }
 
-func selectDeployment(allDeployments []appsv1.Deployment, deploymentName string) *appsv1.Deployment {
-	var deployments []appsv1.Deployment
+// SearchNamespaces returns the namespaces to search for sources in, nil means all namespaces
+// falling back to the namespace of the context
+func (options Options) SearchNamespaces() []string {
+	switch {
+	case options.Namespace != "":
+		return []string{options.Namespace}
+	case options.AllNamespaces:
+		return []string{metav1.NamespaceAll}
+	default:
+		return options.Namespaces
+	}
+}
+
+// findSources returns the sources matching the label selector in the cluster or the sources defined
+// in the file passed to --from-file
+func findSources(k8sClient *k8s.K8s, options Options) []k8s.Source {
+	if options.FromFile == "" {
+		sources, err := k8sClient.Sources(options.LabelSelector, options.Kinds, options.SearchNamespaces())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding console sources: %s\n", err)
+			os.Exit(1)
+		}
+
+		return sources
+	}
+
+	sources, err := k8s.SourcesFromFile(options.FromFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading console sources: %s\n", err)
+		os.Exit(1)
+	} else if len(sources) == 0 {
+		fmt.Fprintf(os.Stderr, "No console sources found in %s\n", options.FromFile)
+		os.Exit(1)
+	}
+
+	// Sources without a namespace use the one passed with --namespace or the namespace of the context
+	namespace := options.Namespace
+	if namespace == "" {
+		namespace = k8sClient.ContextNamespace()
+	}
+
+	for i := range sources {
+		if sources[i].Namespace == "" {
+			sources[i].Namespace = namespace
+		} else if options.Namespace != "" && sources[i].Namespace != options.Namespace {
+			fmt.Fprintf(os.Stderr, "The namespace of %s (%s) does not match the namespace passed with --namespace (%s)\n", sources[i].DisplayName(), sources[i].Namespace, options.Namespace)
+			os.Exit(1)
+		}
+	}
+
+	return sources
+}
+
+func selectSource(allSources []k8s.Source, sourceName string) *k8s.Source {
+	var sources []k8s.Source
 
-	if deploymentName != "" {
-		// If deploymentName is specified then we will filter for deployments matching it
-		for _, d := range allDeployments {
-			if strings.HasPrefix(d.Name, deploymentName) {
-				deployments = append(deployments, d)
+	if sourceName != "" {
+		// If sourceName is specified then we will filter for sources matching it
+		for _, s := range allSources {
+			if strings.HasPrefix(s.Name, sourceName) {
+				sources = append(sources, s)
 			}
 		}
 
-		// If exactly one deployment matches deploymentName then that's the deployment we want to run
-		if len(deployments) == 1 {
-			return &deployments[0]
+		// If exactly one source matches sourceName then that's the source we want to run
+		if len(sources) == 1 {
+			return &sources[0]
 		}
 	}
 
-	// If no deployments matched deploymentName then we let the user pick among all of them
-	if len(deployments) == 0 {
-		deployments = allDeployments
+	// If no sources matched sourceName then we let the user pick among all of them
+	if len(sources) == 0 {
+		sources = allSources
 	}
 
-	deploymentNames := make([]string, len(deployments))
-	for i, d := range deployments {
-		deploymentNames[i] = d.Name
+	// Sources are searched in several namespaces so the namespace is needed to tell them apart
+	sourceNames := make([]string, len(sources))
+	for i, s := range sources {
+		sourceNames[i] = fmt.Sprintf("%s/%s", s.Namespace, s.DisplayName())
 	}
 
-	selectedDeployment := 0
+	selectedSource := 0
 	prompt := &survey.Select{
-		Message: "Choose a deployment:",
-		Options: deploymentNames,
+		Message: "Choose a console:",
+		Options: sourceNames,
 	}
-	err := survey.AskOne(prompt, &selectedDeployment)
+	err := survey.AskOne(prompt, &selectedSource)
 	if err == terminal.InterruptErr {
 		fmt.Println("Cancelled")
 		os.Exit(0)
@@ -303,15 +547,49 @@ func selectDeployment(allDeployments []appsv1.Deployment, deploymentName string)
 		panic(err)
 	}
 
-	return &deployments[selectedDeployment]
+	return &sources[selectedSource]
+}
+
+// waitForContainerExit returns true if the container terminates within containerExitTimeout. The
+// kubelet reports the container as terminated a moment after the attach ends, so it has to be waited for.
+func waitForContainerExit(podsClient v1.PodInterface, pod *apiv1.Pod, containerName string) bool {
+	err := wait.PollUntilContextTimeout(context.TODO(), time.Second, containerExitTimeout, true, func(ctx context.Context) (bool, error) {
+		current, err := podsClient.Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		return slices.ContainsFunc(current.Status.ContainerStatuses, func(status apiv1.ContainerStatus) bool {
+			return status.Name == containerName && status.State.Terminated != nil
+		}), nil
+	})
+
+	return err == nil
 }
 
-func deletePod(pod *apiv1.Pod, podsClient v1.PodInterface) {
+// podFromSource returns a pod built from a copy of the template of the source, so that the template
+// is left untouched and can be compared against the pod
+func podFromSource(source *k8s.Source) *apiv1.Pod {
+	template := source.Template.DeepCopy()
+
+	return &apiv1.Pod{
+		Spec:       template.Spec,
+		ObjectMeta: template.ObjectMeta,
+	}
+}
+
+// deletePod deletes the pod, returning true if it was deleted by this call
+func deletePod(pod *apiv1.Pod, podsClient v1.PodInterface) bool {
 	err := podsClient.Delete(context.TODO(), pod.Name, metav1.DeleteOptions{})
 	if err == nil {
 		fmt.Printf("\nDeleted pod %s/%s\n", pod.Namespace, pod.Name)
+		return true
+	} else if apierrors.IsNotFound(err) {
+		// The pod has already been deleted, for example by the reaper
+		return false
 	} else {
 		fmt.Printf("Failed to delete pod %s/%s: %s\n", pod.Namespace, pod.Name, err)
+		return false
 	}
 }
 
@@ -445,6 +723,9 @@ var ErrPodCompleted = fmt.Errorf("pod ran to completion")
 func podRunningAndReady(event watch.Event) (bool, error) {
 	switch event.Type {
 	case watch.Deleted:
+		if pod, ok := event.Object.(*apiv1.Pod); ok && pod.Annotations[deniedByAnnotation] != "" {
+			return false, &deniedError{by: pod.Annotations[deniedByAnnotation]}
+		}
 		return false, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "")
 	}
 	switch t := event.Object.(type) {
@@ -501,12 +782,14 @@ func heartbeat(pod *apiv1.Pod, podsClient v1.PodInterface) error {
 	return nil
 }
 
-func scheduleHeartbeat(pod *apiv1.Pod, podsClient v1.PodInterface) {
+func scheduleHeartbeat(pod *apiv1.Pod, podsClient v1.PodInterface, onHeartbeat func()) {
 	ticker := time.NewTicker(5 * time.Minute)
 	go func() {
 		for t := range ticker.C {
 			_ = t
-			heartbeat(pod, podsClient)
+			if heartbeat(pod, podsClient) == nil {
+				onHeartbeat()
+			}
 		}
 	}()
 }
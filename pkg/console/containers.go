@@ -0,0 +1,55 @@
+package console
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const (
+	// onlyContainerAnnotation set to "true" only keeps the console container, like --only-container
+	onlyContainerAnnotation = "kubeconsole.only-container"
+	// keepContainersAnnotation is a comma separated list of containers that are kept together with
+	// the console container, like --keep-containers
+	keepContainersAnnotation = "kubeconsole.keep-containers"
+)
+
+// applyOnlyContainer removes all containers and sidecars except the console container and the
+// containers that should be kept, returning the console container in the updated pod
+func applyOnlyContainer(pod *apiv1.Pod, container *apiv1.Container, source *k8s.Source, options Options) (*apiv1.Container, error) {
+	if !options.OnlyContainer && source.Annotations[onlyContainerAnnotation] != "true" {
+		return container, nil
+	}
+
+	keep := options.KeepContainers
+	if keep == nil && source.Annotations[keepContainersAnnotation] != "" {
+		for _, name := range strings.Split(source.Annotations[keepContainersAnnotation], ",") {
+			keep = append(keep, strings.TrimSpace(name))
+		}
+	}
+
+	for _, name := range keep {
+		if findContainer(pod.Spec.Containers, name) == nil && findContainer(pod.Spec.InitContainers, name) == nil {
+			return nil, fmt.Errorf("no container named %s", name)
+		}
+	}
+
+	name := container.Name
+	pod.Spec.Containers = slices.DeleteFunc(pod.Spec.Containers, func(c apiv1.Container) bool {
+		return c.Name != name && !slices.Contains(keep, c.Name)
+	})
+
+	// Init containers that keep running are sidecars
+	pod.Spec.InitContainers = slices.DeleteFunc(pod.Spec.InitContainers, func(c apiv1.Container) bool {
+		return isSidecar(c) && !slices.Contains(keep, c.Name)
+	})
+
+	return findContainer(pod.Spec.Containers, name), nil
+}
+
+func isSidecar(container apiv1.Container) bool {
+	return container.RestartPolicy != nil && *container.RestartPolicy == apiv1.ContainerRestartPolicyAlways
+}
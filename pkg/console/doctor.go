@@ -0,0 +1,118 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// report prints the result of the checks made by Doctor
+type report struct {
+	out    io.Writer
+	failed bool
+}
+
+func (r *report) section(format string, a ...interface{}) {
+	fmt.Fprintf(r.out, format+"\n", a...)
+}
+
+func (r *report) pass(format string, a ...interface{}) {
+	fmt.Fprintf(r.out, "  [PASS] "+format+"\n", a...)
+}
+
+func (r *report) warn(format string, a ...interface{}) {
+	fmt.Fprintf(r.out, "  [WARN] "+format+"\n", a...)
+}
+
+func (r *report) fail(format string, a ...interface{}) {
+	r.failed = true
+	fmt.Fprintf(r.out, "  [FAIL] "+format+"\n", a...)
+}
+
+// Doctor checks that kubeconsole can be used in the environments and prints a report,
+// it returns false if any of the checks failed
+func Doctor(k8s *k8s.K8s, kubeconfig string, kubeconfigErr error, environments []string, options Options) bool {
+	r := &report{out: os.Stdout}
+
+	r.section("Kubeconfig %s", kubeconfig)
+	if kubeconfigErr != nil {
+		r.fail("unable to parse: %s", kubeconfigErr)
+		return false
+	} else if len(k8s.Contexts) == 0 {
+		r.fail("no contexts found")
+		return false
+	}
+	r.pass("parsed, %d contexts found", len(k8s.Contexts))
+
+	for _, environment := range environments {
+		r.section("\nEnvironment %s", environment)
+		checkEnvironment(r, k8s, environment, options)
+	}
+
+	return !r.failed
+}
+
+func checkEnvironment(r *report, k8s *k8s.K8s, environment string, options Options) {
+	if err := k8s.TrySelectContext(environment); err != nil {
+		r.fail("unable to configure client: %s", err)
+		return
+	}
+
+	version, err := k8s.Clientset.Discovery().ServerVersion()
+	if err != nil {
+		r.fail("unable to reach %s: %s", k8s.RestConfig.Host, err)
+		return
+	}
+	r.pass("reachable at %s, server version %s", k8s.RestConfig.Host, version.GitVersion)
+
+	username, err := k8s.Username()
+	if apierrors.IsNotFound(err) {
+		r.warn("unable to check authentication, the cluster does not support SelfSubjectReviews")
+	} else if err != nil {
+		r.fail("not authenticated: %s", err)
+		return
+	} else {
+		r.pass("authenticated as %s", username)
+	}
+
+	namespace := options.Namespace
+	if namespace == "" {
+		namespace = k8s.ContextNamespace()
+	}
+	missing, err := k8s.MissingPermissions(namespace, ConsolePermissions)
+	if err != nil {
+		r.fail("unable to check permissions in %s: %s", namespace, err)
+	} else if len(missing) > 0 {
+		r.fail("missing permissions in %s: %s", namespace, formatPermissions(missing))
+	} else {
+		r.pass("permitted to run consoles in %s", namespace)
+	}
+
+	sources, err := k8s.Sources(options.LabelSelector, options.Kinds, options.SearchNamespaces())
+	if err != nil {
+		r.fail("unable to list console sources: %s", err)
+		return
+	} else if len(sources) == 0 {
+		r.fail("no console sources match the selector %s", options.LabelSelector)
+		return
+	}
+	r.pass("%d console sources match the selector %s", len(sources), options.LabelSelector)
+
+	for _, source := range sources {
+		results := lintSource(source)
+		for _, result := range results {
+			if result.Severity == SeverityError {
+				r.fail("%s: %s", result.Source, result.Message)
+			} else {
+				r.warn("%s: %s", result.Source, result.Message)
+			}
+		}
+
+		if len(results) == 0 {
+			r.pass("%s/%s", source.Namespace, source.DisplayName())
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package console
+
+import (
+	"context"
+	"io"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/printers"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+const (
+	// DryRunClient prints the pod without sending it to the cluster
+	DryRunClient = "client"
+	// DryRunServer submits the pod with DryRun: All so that admission errors surface
+	DryRunServer = "server"
+)
+
+// DryRunStrategies are the valid values for the --dry-run flag
+var DryRunStrategies = []string{DryRunClient, DryRunServer}
+
+// OutputFormats are the valid values for the --output flag
+var OutputFormats = []string{"yaml", "json"}
+
+// dryRun prints the pod that would have been created instead of creating it
+func dryRun(pod *apiv1.Pod, podsClient v1.PodInterface, options Options, out io.Writer) error {
+	if options.DryRun == DryRunServer {
+		result, err := podsClient.Create(context.TODO(), pod, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		if err != nil {
+			return err
+		}
+		pod = result
+	}
+
+	var printer printers.ResourcePrinter = &printers.YAMLPrinter{}
+	if options.Output == "json" {
+		printer = &printers.JSONPrinter{}
+	}
+
+	return printers.NewTypeSetter(scheme.Scheme).ToPrinter(printer).PrintObj(pod, out)
+}
@@ -0,0 +1,70 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	apiv1 "k8s.io/api/core/v1"
+	envutil "k8s.io/kubectl/pkg/cmd/set/env"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// propagatedEnv are the local environment variables that are passed on to the container
+// unless the deployment or the flags already sets them
+var propagatedEnv = []string{"TERM", "LANG", "COLUMNS"}
+
+// applyEnv modifies the environment of the container according to the env flags
+func applyEnv(container *apiv1.Container, options Options) error {
+	for _, name := range propagatedEnv {
+		value, ok := os.LookupEnv(name)
+		if ok && !slices.ContainsFunc(container.Env, func(env apiv1.EnvVar) bool { return env.Name == name }) {
+			setEnv(container, apiv1.EnvVar{Name: name, Value: value})
+		}
+	}
+
+	for _, envFile := range options.EnvFiles {
+		err := cmdutil.AddFromEnvFile(envFile, func(key, value string) error {
+			setEnv(container, apiv1.EnvVar{Name: key, Value: value})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	env, remove, _, err := envutil.ParseEnv(options.Env, nil)
+	if err != nil {
+		return err
+	}
+	for _, e := range env {
+		setEnv(container, e)
+	}
+
+	for _, secret := range options.EnvFromSecrets {
+		container.EnvFrom = append(container.EnvFrom, apiv1.EnvFromSource{
+			SecretRef: &apiv1.SecretEnvSource{LocalObjectReference: apiv1.LocalObjectReference{Name: secret}},
+		})
+	}
+
+	for _, name := range append(remove, options.UnsetEnv...) {
+		if slices.ContainsFunc(env, func(e apiv1.EnvVar) bool { return e.Name == name }) {
+			return fmt.Errorf("can not both set and unset the environment variable %s", name)
+		}
+		container.Env = slices.DeleteFunc(container.Env, func(e apiv1.EnvVar) bool { return e.Name == name })
+	}
+
+	return nil
+}
+
+// setEnv replaces the environment variable with the same name or adds it if it doesn't exist
+func setEnv(container *apiv1.Container, env apiv1.EnvVar) {
+	for i := range container.Env {
+		if container.Env[i].Name == env.Name {
+			container.Env[i] = env
+			return
+		}
+	}
+
+	container.Env = append(container.Env, env)
+}
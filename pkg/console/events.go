@@ -0,0 +1,146 @@
+package console
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/kubectl/pkg/cmd/attach"
+)
+
+// eventComponent is the source of the events recorded by kubeconsole
+const eventComponent = "kubeconsole"
+
+// auditor records the lifecycle of a console session as events on its source, so that the session
+// can be audited after the pod is gone. Sources that don't exist in the cluster, such as the ones
+// read with --from-file, aren't recorded. Failing to record an event doesn't stop the session.
+type auditor struct {
+	eventsClient v1.EventInterface
+	source       *k8s.Source
+	user         string
+	reason       string
+	// extended is the event recorded for the heartbeats, it's updated instead of recording a new
+	// event for every heartbeat
+	extended *apiv1.Event
+}
+
+func newAuditor(k8sClient *k8s.K8s, source *k8s.Source, localUser string, reason string) *auditor {
+	if source.UID == "" {
+		return &auditor{}
+	}
+
+	user, err := k8sClient.Username()
+	if err != nil {
+		user = localUser
+	}
+
+	return &auditor{
+		eventsClient: k8sClient.Clientset.CoreV1().Events(source.Namespace),
+		source:       source,
+		user:         user,
+		reason:       reason,
+	}
+}
+
+func (a *auditor) started(pod *apiv1.Pod, container *apiv1.Container) {
+	message := fmt.Sprintf("%s started console pod %s with image %s", a.user, pod.Name, container.Image)
+	if command := append(append([]string{}, container.Command...), container.Args...); len(command) > 0 {
+		message += fmt.Sprintf(", command: %s", strings.Join(command, " "))
+	}
+	if a.reason != "" {
+		message += fmt.Sprintf(", reason: %s", a.reason)
+	}
+
+	a.record("ConsoleStarted", message)
+}
+
+func (a *auditor) attached(pod *apiv1.Pod) {
+	a.record("ConsoleAttached", fmt.Sprintf("%s attached to console pod %s", a.user, pod.Name))
+}
+
+func (a *auditor) detached(pod *apiv1.Pod, duration time.Duration) {
+	a.record("ConsoleDetached", fmt.Sprintf("%s detached from console pod %s after %s", a.user, pod.Name, duration.Round(time.Second)))
+}
+
+func (a *auditor) ended(pod *apiv1.Pod) {
+	duration := time.Since(pod.CreationTimestamp.Time).Round(time.Second)
+	a.record("ConsoleEnded", fmt.Sprintf("Console pod %s of %s ended after %s", pod.Name, a.user, duration))
+}
+
+// extendedBy records that the heartbeat extended the life of the pod by the timeout
+func (a *auditor) extendedBy(pod *apiv1.Pod, timeout time.Duration) {
+	if a.eventsClient == nil {
+		return
+	}
+
+	if a.extended != nil {
+		a.extended.Count++
+		a.extended.LastTimestamp = metav1.Now()
+		if event, err := a.eventsClient.Update(context.TODO(), a.extended, metav1.UpdateOptions{}); err == nil {
+			a.extended = event
+			return
+		}
+	}
+
+	a.extended = a.record("ConsoleExtended", fmt.Sprintf("%s extended console pod %s by %s", a.user, pod.Name, timeout))
+}
+
+func (a *auditor) record(reason string, message string) *apiv1.Event {
+	if a.eventsClient == nil {
+		return nil
+	}
+
+	now := metav1.Now()
+	event := &apiv1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: a.source.Name + ".",
+			Namespace:    a.source.Namespace,
+		},
+		InvolvedObject: apiv1.ObjectReference{
+			APIVersion: a.source.APIVersion,
+			Kind:       a.source.Kind,
+			Name:       a.source.Name,
+			Namespace:  a.source.Namespace,
+			UID:        a.source.UID,
+		},
+		Reason:              reason,
+		Message:             message,
+		Type:                apiv1.EventTypeNormal,
+		Source:              apiv1.EventSource{Component: eventComponent},
+		ReportingController: eventComponent,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+	}
+
+	event, err := a.eventsClient.Create(context.TODO(), event, metav1.CreateOptions{})
+	if err != nil {
+		return nil
+	}
+
+	return event
+}
+
+// auditRemoteAttach wraps a RemoteAttach so that attaching and detaching is recorded
+type auditRemoteAttach struct {
+	attach.RemoteAttach
+	auditor *auditor
+	pod     *apiv1.Pod
+}
+
+func (a *auditRemoteAttach) Attach(url *url.URL, config *rest.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool, terminalSizeQueue remotecommand.TerminalSizeQueue) error {
+	a.auditor.attached(a.pod)
+	attachedAt := time.Now()
+	defer func() { a.auditor.detached(a.pod, time.Since(attachedAt)) }()
+
+	return a.RemoteAttach.Attach(url, config, stdin, stdout, stderr, tty, terminalSizeQueue)
+}
@@ -0,0 +1,123 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/kubectl/pkg/cmd/attach"
+)
+
+const (
+	// idleTimeoutAnnotation can be set on a deployment to override the idle timeout
+	idleTimeoutAnnotation = "kubeconsole.idle-timeout"
+	// idleWarning is how long before the idle timeout the user is warned
+	idleWarning = time.Minute
+)
+
+// idleMonitor keeps track of when input was last seen on the attached stdin.
+// warn is called once when the session is about to time out and idle is called
+// when the session has timed out.
+type idleMonitor struct {
+	timeout    time.Duration
+	lastActive atomic.Int64
+	warn       func(remaining time.Duration)
+	idle       func()
+}
+
+func newIdleMonitor(timeout time.Duration, warn func(remaining time.Duration), idle func()) *idleMonitor {
+	monitor := &idleMonitor{timeout: timeout, warn: warn, idle: idle}
+	monitor.touch()
+
+	return monitor
+}
+
+func (m *idleMonitor) touch() {
+	m.lastActive.Store(time.Now().UnixNano())
+}
+
+func (m *idleMonitor) idleFor() time.Duration {
+	return time.Since(time.Unix(0, m.lastActive.Load()))
+}
+
+// run blocks until the session has been idle for the timeout
+func (m *idleMonitor) run() {
+	warnAfter := m.timeout - idleWarning
+	if m.timeout <= 2*idleWarning {
+		warnAfter = m.timeout / 2
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	warned := false
+	for range ticker.C {
+		idleFor := m.idleFor()
+
+		switch {
+		case idleFor >= m.timeout:
+			m.idle()
+			return
+		case idleFor >= warnAfter && !warned:
+			warned = true
+			m.warn(m.timeout - idleFor)
+		case idleFor < warnAfter:
+			warned = false
+		}
+	}
+}
+
+// idleReader marks the monitor as active whenever something is read
+type idleReader struct {
+	io.Reader
+	monitor *idleMonitor
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.monitor.touch()
+	}
+
+	return n, err
+}
+
+// idleRemoteAttach wraps a RemoteAttach so that stdin activity is reported to the monitor.
+// The stdin stream is replaced by kubectl when setting up the TTY so it has to be wrapped here,
+// this is also where the monitor is started so that time spent waiting for the pod isn't counted.
+type idleRemoteAttach struct {
+	attach.RemoteAttach
+	monitor *idleMonitor
+}
+
+func (a *idleRemoteAttach) Attach(url *url.URL, config *rest.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool, terminalSizeQueue remotecommand.TerminalSizeQueue) error {
+	if stdin != nil {
+		stdin = &idleReader{Reader: stdin, monitor: a.monitor}
+	}
+
+	a.monitor.touch()
+	go a.monitor.run()
+
+	return a.RemoteAttach.Attach(url, config, stdin, stdout, stderr, tty, terminalSizeQueue)
+}
+
+// idleTimeout returns the idle timeout for a deployment, the annotation takes precedence over the flag
+func idleTimeout(annotations map[string]string, fallback time.Duration) time.Duration {
+	value, ok := annotations[idleTimeoutAnnotation]
+	if !ok {
+		return fallback
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring invalid %s annotation %q: %s\n", idleTimeoutAnnotation, value, err)
+		return fallback
+	}
+
+	return timeout
+}
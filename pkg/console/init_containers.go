@@ -0,0 +1,61 @@
+package console
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// initContainersAnnotation sets which init containers are kept by default, either "none" or a
+// comma separated list of init container names. All init containers are kept if it's not set.
+const initContainersAnnotation = "kubeconsole.init-containers"
+
+// applyInitContainers removes the init containers that shouldn't run before the console. Native
+// sidecars are init containers too but they're left to applyOnlyContainer.
+func applyInitContainers(pod *apiv1.Pod, source *k8s.Source, options Options) error {
+	keep, ok := initContainersToKeep(source, options)
+	if !ok {
+		return nil
+	}
+
+	for _, name := range keep {
+		if container := findContainer(pod.Spec.InitContainers, name); container == nil || isSidecar(*container) {
+			return fmt.Errorf("no init container named %s", name)
+		}
+	}
+
+	pod.Spec.InitContainers = slices.DeleteFunc(pod.Spec.InitContainers, func(container apiv1.Container) bool {
+		return !isSidecar(container) && !slices.Contains(keep, container.Name)
+	})
+
+	return nil
+}
+
+// initContainersToKeep returns the names of the init containers to keep, the second return value
+// is false if all of them should be kept
+func initContainersToKeep(source *k8s.Source, options Options) ([]string, bool) {
+	switch {
+	case options.SkipInit:
+		return nil, true
+	case options.InitContainers != nil:
+		return options.InitContainers, true
+	}
+
+	value := strings.TrimSpace(source.Annotations[initContainersAnnotation])
+	switch value {
+	case "":
+		return nil, false
+	case "none":
+		return nil, true
+	}
+
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		names = append(names, strings.TrimSpace(name))
+	}
+
+	return names, true
+}
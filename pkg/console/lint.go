@@ -0,0 +1,227 @@
+package console
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/kubectl/pkg/cmd/util/podcmd"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// SeverityError is used for problems that stops the console from working as intended
+	SeverityError = "error"
+	// SeverityWarning is used for problems that might be intentional
+	SeverityWarning = "warning"
+)
+
+// LintFormats are the valid values for the lint --output flag
+var LintFormats = []string{"text", "json", "yaml"}
+
+// lintRule checks a source, returning a message for each problem found
+type lintRule struct {
+	Name     string
+	Severity string
+	Check    func(source k8s.Source) []string
+}
+
+// LintResult is a problem found by a lint rule
+type LintResult struct {
+	Source   string `json:"source"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+var lintRules = []lintRule{
+	{Name: "containers", Severity: SeverityError, Check: lintContainers},
+	{Name: "default-container", Severity: SeverityError, Check: lintDefaultContainer},
+	{Name: "default-container-annotation", Severity: SeverityWarning, Check: lintDefaultContainerAnnotation},
+	{Name: "replicas", Severity: SeverityError, Check: lintReplicas},
+	{Name: "probes", Severity: SeverityError, Check: lintProbes},
+	{Name: "init-containers", Severity: SeverityWarning, Check: lintInitContainers},
+	{Name: "persistent-volume-claims", Severity: SeverityWarning, Check: lintPersistentVolumeClaims},
+}
+
+// Lint evaluates the sources in the environment, or in the file passed with --from-file, against
+// the lint rules and prints the results. It returns false if any errors were found.
+func Lint(k8sClient *k8s.K8s, environment string, options Options, format string) bool {
+	if environment != "" {
+		k8sClient.SelectContext(environment)
+	}
+
+	results := lintSources(findSources(k8sClient, options))
+	if err := printLintResults(results, format, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error printing lint results: %s\n", err)
+		return false
+	}
+
+	return !hasLintErrors(results)
+}
+
+// lintSources evaluates the sources against the lint rules
+func lintSources(sources []k8s.Source) []LintResult {
+	results := []LintResult{}
+
+	for _, source := range sources {
+		results = append(results, lintSource(source)...)
+	}
+
+	return results
+}
+
+func lintSource(source k8s.Source) []LintResult {
+	var results []LintResult
+
+	for _, rule := range lintRules {
+		for _, message := range rule.Check(source) {
+			results = append(results, LintResult{
+				Source:   fmt.Sprintf("%s/%s", source.Namespace, source.DisplayName()),
+				Rule:     rule.Name,
+				Severity: rule.Severity,
+				Message:  message,
+			})
+		}
+	}
+
+	return results
+}
+
+// printLintResults prints the results in the format, one of LintFormats
+func printLintResults(results []LintResult, format string, out io.Writer) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	case "yaml":
+		data, err := yaml.Marshal(results)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	default:
+		for _, result := range results {
+			fmt.Fprintf(out, "%s: %s: %s (%s)\n", result.Source, result.Severity, result.Message, result.Rule)
+		}
+		return nil
+	}
+}
+
+// hasLintErrors returns true if any of the results is an error
+func hasLintErrors(results []LintResult) bool {
+	for _, result := range results {
+		if result.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lintContainers(source k8s.Source) []string {
+	if len(source.Template.Spec.Containers) == 0 {
+		return []string{"the pod template has no containers"}
+	}
+
+	var messages []string
+	for _, container := range source.Template.Spec.Containers {
+		if container.Image == "" {
+			messages = append(messages, fmt.Sprintf("the container %s has no image", container.Name))
+		}
+	}
+
+	return messages
+}
+
+func lintDefaultContainer(source k8s.Source) []string {
+	name := source.Template.Annotations[podcmd.DefaultContainerAnnotationName]
+	if name == "" || findContainer(source.Template.Spec.Containers, name) != nil {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("the default container %s does not exist", name)}
+}
+
+func lintDefaultContainerAnnotation(source k8s.Source) []string {
+	if len(source.Template.Spec.Containers) < 2 || source.Template.Annotations[podcmd.DefaultContainerAnnotationName] != "" {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("the pod template has several containers but no %s annotation, the first container is used", podcmd.DefaultContainerAnnotationName)}
+}
+
+func lintReplicas(source k8s.Source) []string {
+	if source.Replicas == nil || *source.Replicas == 0 {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("has %d replicas, console sources should have 0 replicas", *source.Replicas)}
+}
+
+func lintProbes(source k8s.Source) []string {
+	var messages []string
+
+	for _, container := range source.Template.Spec.Containers {
+		var probes []string
+		if container.LivenessProbe != nil {
+			probes = append(probes, "liveness")
+		}
+		if container.ReadinessProbe != nil {
+			probes = append(probes, "readiness")
+		}
+		if container.StartupProbe != nil {
+			probes = append(probes, "startup")
+		}
+
+		if len(probes) > 0 {
+			messages = append(messages, fmt.Sprintf("the container %s has %s probes which can keep the console from becoming ready or restart it", container.Name, strings.Join(probes, ", ")))
+		}
+	}
+
+	return messages
+}
+
+func lintInitContainers(source k8s.Source) []string {
+	// Native sidecars can't be skipped with the annotation and don't run to completion
+	var names []string
+	for _, container := range source.Template.Spec.InitContainers {
+		if !isSidecar(container) {
+			names = append(names, container.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("the init containers %s run before every console, make sure they don't run migrations or skip them with the %s annotation", strings.Join(names, ", "), initContainersAnnotation)}
+}
+
+func lintPersistentVolumeClaims(source k8s.Source) []string {
+	var messages []string
+
+	for _, volume := range source.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			messages = append(messages, fmt.Sprintf("mounts the claim %s, ReadWriteOnce claims can only be used on the node they are attached to", volume.PersistentVolumeClaim.ClaimName))
+		}
+	}
+
+	return messages
+}
+
+func findContainer(containers []apiv1.Container, name string) *apiv1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+
+	return nil
+}
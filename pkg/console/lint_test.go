@@ -0,0 +1,152 @@
+package console
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+)
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		rules    []string
+	}{
+		{
+			name: "valid",
+			manifest: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: console}
+spec:
+  replicas: 0
+  selector: {matchLabels: {app: console}}
+  template:
+    metadata: {labels: {app: console}}
+    spec:
+      containers: [{name: app, image: app:1}]
+`,
+		},
+		{
+			name: "replicas defaulted",
+			manifest: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: console}
+spec:
+  selector: {matchLabels: {app: console}}
+  template:
+    metadata: {labels: {app: console}}
+    spec:
+      containers: [{name: app, image: app:1}]
+`,
+			rules: []string{"replicas"},
+		},
+		{
+			name: "pod template without replicas",
+			manifest: `
+apiVersion: v1
+kind: PodTemplate
+metadata: {name: console}
+template:
+  spec:
+    containers: [{name: app, image: app:1}]
+`,
+		},
+		{
+			name: "containers",
+			manifest: `
+apiVersion: v1
+kind: PodTemplate
+metadata: {name: console}
+template:
+  spec:
+    containers: [{name: app}]
+`,
+			rules: []string{"containers"},
+		},
+		{
+			name: "default container",
+			manifest: `
+apiVersion: v1
+kind: PodTemplate
+metadata: {name: console}
+template:
+  metadata:
+    annotations: {kubectl.kubernetes.io/default-container: missing}
+  spec:
+    containers: [{name: app, image: app:1}, {name: proxy, image: proxy:1}]
+`,
+			rules: []string{"default-container"},
+		},
+		{
+			name: "default container annotation",
+			manifest: `
+apiVersion: v1
+kind: PodTemplate
+metadata: {name: console}
+template:
+  spec:
+    containers: [{name: app, image: app:1}, {name: proxy, image: proxy:1}]
+`,
+			rules: []string{"default-container-annotation"},
+		},
+		{
+			name: "probes",
+			manifest: `
+apiVersion: v1
+kind: PodTemplate
+metadata: {name: console}
+template:
+  spec:
+    containers:
+      - name: app
+        image: app:1
+        readinessProbe: {httpGet: {path: /, port: 80}}
+`,
+			rules: []string{"probes"},
+		},
+		{
+			name: "init containers and claims",
+			manifest: `
+apiVersion: v1
+kind: PodTemplate
+metadata: {name: console}
+template:
+  spec:
+    initContainers:
+      - {name: migrate, image: app:1}
+      - {name: proxy, image: proxy:1, restartPolicy: Always}
+    containers: [{name: app, image: app:1}]
+    volumes: [{name: data, persistentVolumeClaim: {claimName: data}}]
+`,
+			rules: []string{"init-containers", "persistent-volume-claims"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "console.yaml")
+			if err := os.WriteFile(path, []byte(test.manifest), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			sources, err := k8s.SourcesFromFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rules []string
+			for _, result := range lintSources(sources) {
+				rules = append(rules, result.Rule)
+			}
+
+			if !slices.Equal(rules, test.rules) {
+				t.Errorf("expected the rules %v to fail, got %v", test.rules, rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package console
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/kubectl/pkg/cmd/exec"
+)
+
+// mesh describes how a service mesh injects its proxy into pods
+type mesh struct {
+	Name           string
+	ProxyContainer string
+	// InjectAnnotation is set on pods and namespaces to enable or disable injection
+	InjectAnnotation string
+	EnabledValue     string
+	DisabledValue    string
+	// NamespaceLabels enables injection when set on the namespace, an empty value matches any value
+	NamespaceLabels map[string]string
+	// Annotations makes the proxy start before the console container and, where supported,
+	// run as a native sidecar that stops when the console exits
+	Annotations map[string]string
+	// QuitCommand is run in the proxy container to stop it when it doesn't stop by itself
+	QuitCommand []string
+}
+
+var meshes = []mesh{
+	{
+		Name:             "istio",
+		ProxyContainer:   "istio-proxy",
+		InjectAnnotation: "sidecar.istio.io/inject",
+		EnabledValue:     "true",
+		DisabledValue:    "false",
+		NamespaceLabels:  map[string]string{"istio-injection": "enabled", "istio.io/rev": ""},
+		Annotations:      map[string]string{"proxy.istio.io/config": `{"holdApplicationUntilProxyStarts": true}`},
+		QuitCommand:      []string{"pilot-agent", "request", "POST", "quitquitquit"},
+	},
+	{
+		Name:             "linkerd",
+		ProxyContainer:   "linkerd-proxy",
+		InjectAnnotation: "linkerd.io/inject",
+		EnabledValue:     "enabled",
+		DisabledValue:    "disabled",
+		Annotations: map[string]string{
+			"config.linkerd.io/proxy-await":                       "enabled",
+			"config.alpha.linkerd.io/proxy-enable-native-sidecar": "true",
+		},
+	},
+}
+
+// prepareMeshes disables injection of service mesh proxies if --no-mesh is used, otherwise the
+// annotations that makes the proxies start before the console are added for the meshes that will
+// inject a proxy into the pod
+func prepareMeshes(pod *apiv1.Pod, namespace *apiv1.Namespace, options Options) {
+	if options.NoMesh {
+		for _, m := range meshes {
+			pod.Annotations[m.InjectAnnotation] = m.DisabledValue
+		}
+		return
+	}
+
+	for _, m := range meshes {
+		if !m.injects(pod, namespace) {
+			continue
+		}
+
+		for key, value := range m.Annotations {
+			if _, ok := pod.Annotations[key]; !ok {
+				pod.Annotations[key] = value
+			}
+		}
+	}
+}
+
+func (m mesh) injects(pod *apiv1.Pod, namespace *apiv1.Namespace) bool {
+	switch pod.Annotations[m.InjectAnnotation] {
+	case m.EnabledValue:
+		return true
+	case m.DisabledValue:
+		return false
+	}
+
+	if namespace == nil {
+		return false
+	}
+
+	if namespace.Annotations[m.InjectAnnotation] == m.EnabledValue {
+		return true
+	}
+
+	for key, value := range m.NamespaceLabels {
+		if label, ok := namespace.Labels[key]; ok && (value == "" || label == value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// injectedMeshes returns the meshes that has injected a proxy into the pod
+func injectedMeshes(pod *apiv1.Pod) []mesh {
+	var injected []mesh
+
+	for _, m := range meshes {
+		if findContainer(pod.Spec.Containers, m.ProxyContainer) != nil || findContainer(pod.Spec.InitContainers, m.ProxyContainer) != nil {
+			injected = append(injected, m)
+		}
+	}
+
+	return injected
+}
+
+// stopMeshProxies stops the proxies that keep running after the console container has exited so
+// that the pod can complete. Proxies running as native sidecars stop by themselves.
+func stopMeshProxies(k8sClient *k8s.K8s, pod *apiv1.Pod) {
+	for _, m := range injectedMeshes(pod) {
+		if len(m.QuitCommand) == 0 || findContainer(pod.Spec.Containers, m.ProxyContainer) == nil {
+			continue
+		}
+
+		if err := execInContainer(k8sClient, pod, m.ProxyContainer, m.QuitCommand); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to stop the %s proxy: %s\n", m.Name, err)
+		}
+	}
+}
+
+func execInContainer(k8sClient *k8s.K8s, pod *apiv1.Pod, containerName string, command []string) error {
+	restClient, err := rest.RESTClientFor(k8sClient.RestConfig)
+	if err != nil {
+		return err
+	}
+
+	req := restClient.Post().
+		Resource("pods").
+		Name(pod.Name).
+		Namespace(pod.Namespace).
+		SubResource("exec")
+	req.VersionedParams(&apiv1.PodExecOptions{
+		Container: containerName,
+		Command:   command,
+		Stdout:    true,
+		Stderr:    true,
+	}, scheme.ParameterCodec)
+
+	var stderr bytes.Buffer
+	executor := &exec.DefaultRemoteExecutor{}
+	if err := executor.Execute(req.URL(), k8sClient.RestConfig, nil, &bytes.Buffer{}, &stderr, false, nil); err != nil {
+		return fmt.Errorf("%w: %s", err, stderr.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// OverrideTypes are the valid values for the --overrides-type flag
+var OverrideTypes = []string{
+	string(cmdutil.OverrideTypeStrategic),
+	string(cmdutil.OverrideTypeMerge),
+	string(cmdutil.OverrideTypeJSON),
+}
+
+// applyOverrides patches the pod with the overrides, which can be inline JSON or YAML or
+// a path to a file prefixed with @
+func applyOverrides(pod *apiv1.Pod, overrides string, overrideType string) (*apiv1.Pod, error) {
+	fragment := []byte(overrides)
+
+	if path, ok := strings.CutPrefix(overrides, "@"); ok {
+		var err error
+		fragment, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading overrides: %w", err)
+		}
+	}
+
+	fragment, err := yaml.YAMLToJSON(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("parsing overrides: %w", err)
+	}
+
+	// The codec used for patching requires the type to be set
+	pod.APIVersion = "v1"
+	pod.Kind = "Pod"
+
+	// The patched pod is decoded strictly so that typos in the overrides are reported instead of dropped
+	codec := runtime.NewCodec(
+		scheme.DefaultJSONEncoder(),
+		serializer.NewCodecFactory(scheme.Scheme, serializer.EnableStrict).UniversalDecoder(scheme.Scheme.PrioritizedVersionsAllGroups()...),
+	)
+
+	var obj runtime.Object
+	switch cmdutil.OverrideType(overrideType) {
+	case cmdutil.OverrideTypeJSON:
+		obj, err = cmdutil.JSONPatch(codec, pod, string(fragment))
+	case cmdutil.OverrideTypeMerge:
+		obj, err = cmdutil.Merge(codec, pod, string(fragment))
+	default:
+		obj, err = cmdutil.StrategicMerge(codec, pod, string(fragment), &apiv1.Pod{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("applying overrides: %w", err)
+	}
+
+	patchedPod, ok := obj.(*apiv1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("applying overrides: expected a Pod but got %s", obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+
+	return patchedPod, nil
+}
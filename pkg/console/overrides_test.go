@@ -0,0 +1,43 @@
+package console
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	tests := []struct {
+		name         string
+		overrides    string
+		overrideType string
+		err          string
+	}{
+		{name: "strategic", overrides: `{"spec":{"nodeSelector":{"pool":"consoles"}}}`, overrideType: "strategic"},
+		{name: "merge", overrides: `spec: {nodeSelector: {pool: consoles}}`, overrideType: "merge"},
+		{name: "json", overrides: `[{"op":"add","path":"/spec/nodeSelector","value":{"pool":"consoles"}}]`, overrideType: "json"},
+		{name: "unknown field", overrides: `{"spec":{"nodeSelecter":{"pool":"consoles"}}}`, overrideType: "strategic", err: `unknown field "spec.nodeSelecter"`},
+		{name: "unknown field merge", overrides: `{"spec":{"nodeSelecter":{"pool":"consoles"}}}`, overrideType: "merge", err: `unknown field "spec.nodeSelecter"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &apiv1.Pod{Spec: apiv1.PodSpec{Containers: []apiv1.Container{{Name: "app", Image: "app:1"}}}}
+
+			patched, err := applyOverrides(pod, test.overrides, test.overrideType)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if patched.Spec.NodeSelector["pool"] != "consoles" {
+				t.Errorf("expected the node selector to be set, got %v", patched.Spec.NodeSelector)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package console
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// policyConfigMap is the name of the ConfigMap holding the policy of the consoles in a namespace
+	policyConfigMap = "kubeconsole-policy"
+	// policyAnnotationPrefix prefixes the policy keys when set as annotations on a deployment,
+	// they take precedence over the keys of the ConfigMap
+	policyAnnotationPrefix = "kubeconsole.policy."
+	// sourceAnnotation is set on the pod to the source it was created from, so that the policy
+	// can be enforced by the reaper as well
+	sourceAnnotation = "kubeconsole.source"
+	// timeoutAnnotation is the number of minutes the reaper keeps the pod after the heartbeat stopped
+	timeoutAnnotation = "kubeconsole.timeout"
+)
+
+// policyKeys are the keys of the ConfigMap and the annotation suffixes of a policy
+var policyKeys = []string{"allowed-images", "allow-root", "max-limits", "max-timeout", "allowed-groups"}
+
+// policy restricts how a console may be changed from its pod template
+type policy struct {
+	// AllowedImages are patterns for images that may replace the images of the template, * matches anything
+	AllowedImages []string
+	// AllowRoot is whether the console may run as root when the template doesn't
+	AllowRoot *bool
+	// MaxLimits are the largest limits and requests the console container may have
+	MaxLimits apiv1.ResourceList
+	// MaxTimeout is the longest timeout that may be used
+	MaxTimeout time.Duration
+	// AllowedGroups are the groups allowed to run the console, the user has to be in one of them
+	AllowedGroups []string
+}
+
+// loadPolicy reads the policy from the ConfigMap in the namespace of the source and the annotations
+// of the source. A ConfigMap that can't be read because of missing permissions is ignored.
+func loadPolicy(k8sClient *k8s.K8s, source *k8s.Source) (policy, error) {
+	values := map[string]string{}
+
+	configMap, err := k8sClient.Clientset.CoreV1().ConfigMaps(source.Namespace).Get(context.TODO(), policyConfigMap, metav1.GetOptions{})
+	if apierrors.IsForbidden(err) {
+		fmt.Fprintf(os.Stderr, "Not permitted to read the %s ConfigMap in %s, only the policy annotations are enforced\n", policyConfigMap, source.Namespace)
+	} else if err != nil && !apierrors.IsNotFound(err) {
+		return policy{}, err
+	} else if err == nil {
+		for _, key := range policyKeys {
+			if value, ok := configMap.Data[key]; ok {
+				values[key] = value
+			}
+		}
+	}
+
+	for _, key := range policyKeys {
+		if value, ok := source.Annotations[policyAnnotationPrefix+key]; ok {
+			values[key] = value
+		}
+	}
+
+	return parsePolicy(values)
+}
+
+func parsePolicy(values map[string]string) (policy, error) {
+	var p policy
+
+	p.AllowedImages = splitList(values["allowed-images"])
+	p.AllowedGroups = splitList(values["allowed-groups"])
+
+	if value, ok := values["allow-root"]; ok {
+		allowRoot, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return p, fmt.Errorf("invalid allow-root %q: %w", value, err)
+		}
+		p.AllowRoot = &allowRoot
+	}
+
+	if value, ok := values["max-timeout"]; ok {
+		maxTimeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return p, fmt.Errorf("invalid max-timeout %q: %w", value, err)
+		}
+		p.MaxTimeout = maxTimeout
+	}
+
+	for _, limit := range splitList(values["max-limits"]) {
+		name, value, ok := strings.Cut(limit, "=")
+		if !ok {
+			return p, fmt.Errorf("invalid max-limits %q, expected for example cpu=2,memory=4Gi", limit)
+		}
+
+		quantity, err := resource.ParseQuantity(strings.TrimSpace(value))
+		if err != nil {
+			return p, fmt.Errorf("invalid max-limits %q: %w", limit, err)
+		}
+
+		if p.MaxLimits == nil {
+			p.MaxLimits = apiv1.ResourceList{}
+		}
+		p.MaxLimits[apiv1.ResourceName(strings.TrimSpace(name))] = quantity
+	}
+
+	return p, nil
+}
+
+// splitList splits a comma or newline separated list, ignoring empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// violations returns how the pod breaks the policy, the template is what the pod was created from
+// and is always allowed
+func (p policy) violations(k8sClient *k8s.K8s, pod *apiv1.Pod, template apiv1.PodTemplateSpec) []string {
+	var violations []string
+
+	if len(p.AllowedImages) > 0 {
+		forEachContainer(pod, func(c *apiv1.Container) {
+			if !templateHasImage(template, c.Image) && !slices.ContainsFunc(p.AllowedImages, func(pattern string) bool { return matchImage(pattern, c.Image) }) {
+				violations = append(violations, fmt.Sprintf("the image %s is not allowed, allowed images are: %s", c.Image, strings.Join(p.AllowedImages, ", ")))
+			}
+		})
+	}
+
+	if p.AllowRoot != nil && !*p.AllowRoot {
+		templatePod := &apiv1.Pod{Spec: template.Spec}
+		forEachContainer(pod, func(c *apiv1.Container) {
+			templateContainer := findTemplateContainer(template, c.Name)
+			if runsAsRoot(pod, c) && (templateContainer == nil || !runsAsRoot(templatePod, templateContainer)) {
+				violations = append(violations, fmt.Sprintf("running the %s container as root is not allowed", c.Name))
+			}
+		})
+	}
+
+	forEachContainer(pod, func(c *apiv1.Container) {
+		for name, max := range p.MaxLimits {
+			for _, resources := range []apiv1.ResourceList{c.Resources.Limits, c.Resources.Requests} {
+				if quantity, ok := resources[name]; ok && quantity.Cmp(max) > 0 {
+					violations = append(violations, fmt.Sprintf("the %s %s of the %s container exceeds the maximum of %s", name, quantity.String(), c.Name, max.String()))
+					break
+				}
+			}
+		}
+	})
+
+	// The timeout is read from the annotation the reaper uses since the overrides can change it
+	if p.MaxTimeout > 0 {
+		minutes, err := strconv.Atoi(pod.Annotations[timeoutAnnotation])
+		if timeout := time.Duration(minutes) * time.Minute; err != nil || minutes <= 0 {
+			violations = append(violations, fmt.Sprintf("the %s annotation %q is not a positive number of minutes", timeoutAnnotation, pod.Annotations[timeoutAnnotation]))
+		} else if timeout > p.MaxTimeout {
+			violations = append(violations, fmt.Sprintf("the timeout %s exceeds the maximum of %s", timeout, p.MaxTimeout))
+		}
+	}
+
+	if len(p.AllowedGroups) > 0 {
+		userInfo, err := k8sClient.UserInfo()
+		if err != nil {
+			violations = append(violations, fmt.Sprintf("unable to check the groups of the user: %s", err))
+		} else if !slices.ContainsFunc(userInfo.Groups, func(group string) bool { return slices.Contains(p.AllowedGroups, group) }) {
+			violations = append(violations, fmt.Sprintf("%s is not in any of the allowed groups: %s", userInfo.Username, strings.Join(p.AllowedGroups, ", ")))
+		}
+	}
+
+	return violations
+}
+
+// enforcePolicy exits if the pod breaks the policy of the source
+func enforcePolicy(k8sClient *k8s.K8s, pod *apiv1.Pod, source *k8s.Source) {
+	p, err := loadPolicy(k8sClient, source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to load the policy of %s: %s\n", source.DisplayName(), err)
+		os.Exit(1)
+	}
+
+	violations := p.violations(k8sClient, pod, source.Template)
+	if len(violations) > 0 {
+		fmt.Fprintf(os.Stderr, "The console is not allowed by the policy of %s:\n", source.DisplayName())
+		for _, violation := range violations {
+			fmt.Fprintf(os.Stderr, "  - %s\n", violation)
+		}
+		os.Exit(1)
+	}
+}
+
+func templateHasImage(template apiv1.PodTemplateSpec, image string) bool {
+	isImage := func(c apiv1.Container) bool { return c.Image == image }
+	return slices.ContainsFunc(template.Spec.Containers, isImage) || slices.ContainsFunc(template.Spec.InitContainers, isImage)
+}
+
+// matchImage matches an image against a pattern where * matches any characters, including /
+func matchImage(pattern string, image string) bool {
+	expression := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	matched, _ := regexp.MatchString(expression, image)
+
+	return matched
+}
+
+// findTemplateContainer returns the container or init container of the template with the name
+func findTemplateContainer(template apiv1.PodTemplateSpec, name string) *apiv1.Container {
+	if container := findContainer(template.Spec.Containers, name); container != nil {
+		return container
+	}
+
+	return findContainer(template.Spec.InitContainers, name)
+}
+
+// runsAsRoot returns true if the container runs as uid 0 or is privileged, the container security
+// context takes precedence over the pod security context. Images running as root by default aren't
+// detected.
+func runsAsRoot(pod *apiv1.Pod, container *apiv1.Container) bool {
+	if container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+		return true
+	}
+	if container.SecurityContext != nil && container.SecurityContext.RunAsUser != nil {
+		return *container.SecurityContext.RunAsUser == 0
+	}
+
+	return pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.RunAsUser != nil && *pod.Spec.SecurityContext.RunAsUser == 0
+}
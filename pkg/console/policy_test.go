@@ -0,0 +1,146 @@
+package console
+
+import (
+	"testing"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func testSource() *k8s.Source {
+	return &k8s.Source{
+		Template: apiv1.PodTemplateSpec{
+			Spec: apiv1.PodSpec{
+				Containers: []apiv1.Container{{Name: "app", Image: "app:1"}},
+			},
+		},
+	}
+}
+
+// testPod creates the pod like Start does before the policy is enforced
+func testPod(source *k8s.Source) *apiv1.Pod {
+	pod := podFromSource(source)
+	pod.Annotations = map[string]string{timeoutAnnotation: "15"}
+
+	return pod
+}
+
+func testPolicy(t *testing.T) policy {
+	p, err := parsePolicy(map[string]string{
+		"allowed-images": "good:*",
+		"allow-root":     "false",
+		"max-limits":     "cpu=2,memory=4Gi",
+		"max-timeout":    "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestPolicyViolationsAllowsTemplate(t *testing.T) {
+	source := testSource()
+	pod := testPod(source)
+
+	violations := testPolicy(t).violations(nil, pod, source.Template)
+	if len(violations) != 0 {
+		t.Errorf("expected no violations, got %v", violations)
+	}
+}
+
+func TestPolicyViolationsImage(t *testing.T) {
+	source := testSource()
+	pod := testPod(source)
+	container := &pod.Spec.Containers[0]
+
+	container.Image = "evil:latest"
+	violations := testPolicy(t).violations(nil, pod, source.Template)
+	if len(violations) != 1 {
+		t.Errorf("expected the image to be denied, got %v", violations)
+	}
+	if image := source.Template.Spec.Containers[0].Image; image != "app:1" {
+		t.Errorf("expected the template to be untouched, got the image %s", image)
+	}
+
+	container.Image = "good:2"
+	violations = testPolicy(t).violations(nil, pod, source.Template)
+	if len(violations) != 0 {
+		t.Errorf("expected the image to be allowed, got %v", violations)
+	}
+}
+
+func TestPolicyViolationsRoot(t *testing.T) {
+	for _, profile := range []string{SecurityProfileRoot, SecurityProfileDebug} {
+		source := testSource()
+		pod := testPod(source)
+		container := &pod.Spec.Containers[0]
+
+		applySecurityProfile(pod, container, profile)
+		violations := testPolicy(t).violations(nil, pod, source.Template)
+		if len(violations) != 1 {
+			t.Errorf("expected the %s profile to be denied, got %v", profile, violations)
+		}
+	}
+}
+
+func TestPolicyViolationsRootInTemplate(t *testing.T) {
+	source := testSource()
+	runAsUser := int64(0)
+	source.Template.Spec.SecurityContext = &apiv1.PodSecurityContext{RunAsUser: &runAsUser}
+	pod := testPod(source)
+	container := &pod.Spec.Containers[0]
+
+	applySecurityProfile(pod, container, SecurityProfileRoot)
+	violations := testPolicy(t).violations(nil, pod, source.Template)
+	if len(violations) != 0 {
+		t.Errorf("expected a template running as root to be allowed, got %v", violations)
+	}
+}
+
+func TestPolicyViolationsLimitsAndTimeout(t *testing.T) {
+	source := testSource()
+	pod := testPod(source)
+	container := &pod.Spec.Containers[0]
+
+	container.Resources.Limits = apiv1.ResourceList{apiv1.ResourceMemory: resource.MustParse("8Gi")}
+	pod.Annotations[timeoutAnnotation] = "120"
+	violations := testPolicy(t).violations(nil, pod, source.Template)
+	if len(violations) != 2 {
+		t.Errorf("expected the limits and timeout to be denied, got %v", violations)
+	}
+}
+
+func TestPolicyViolationsTimeoutAnnotation(t *testing.T) {
+	for value, allowed := range map[string]bool{"15": true, "60": true, "61": false, "0": false, "": false, "1h": false} {
+		pod := testPod(testSource())
+		pod.Annotations[timeoutAnnotation] = value
+
+		violations := testPolicy(t).violations(nil, pod, testSource().Template)
+		if allowed && len(violations) != 0 {
+			t.Errorf("expected the timeout %q to be allowed, got %v", value, violations)
+		} else if !allowed && len(violations) != 1 {
+			t.Errorf("expected the timeout %q to be denied, got %v", value, violations)
+		}
+	}
+}
+
+func TestPolicyViolationsAddedContainer(t *testing.T) {
+	source := testSource()
+	pod := testPod(source)
+
+	privileged := true
+	pod.Spec.Containers = append(pod.Spec.Containers, apiv1.Container{
+		Name:            "sidecar",
+		Image:           "good:1",
+		SecurityContext: &apiv1.SecurityContext{Privileged: &privileged},
+		Resources: apiv1.ResourceRequirements{
+			Requests: apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("4")},
+		},
+	})
+	violations := testPolicy(t).violations(nil, pod, source.Template)
+	if len(violations) != 2 {
+		t.Errorf("expected the privileged container and its requests to be denied, got %v", violations)
+	}
+}
@@ -0,0 +1,61 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+)
+
+// ConsolePermissions are the permissions needed to run a console
+var ConsolePermissions = []k8s.Permission{
+	{Verb: "create", Resource: "pods"},
+	{Verb: "get", Resource: "pods"},
+	{Verb: "list", Resource: "pods"},
+	{Verb: "watch", Resource: "pods"},
+	{Verb: "patch", Resource: "pods"},
+	{Verb: "delete", Resource: "pods"},
+	{Verb: "create", Resource: "pods", Subresource: "attach"},
+	{Verb: "list", Resource: "events"},
+	{Verb: "watch", Resource: "events"},
+}
+
+// preflight exits with the missing permissions if the user can't run a console in the namespace
+func preflight(k8sClient *k8s.K8s, namespace string, options Options) {
+	permissions := ConsolePermissions
+	if options.NoRm {
+		permissions = withoutPermission(permissions, k8s.Permission{Verb: "delete", Resource: "pods"})
+	}
+
+	missing, err := k8sClient.MissingPermissions(namespace, permissions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to check permissions, continuing anyway: %s\n", err)
+		return
+	}
+
+	if len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "Missing permissions in the %s namespace: %s\n", namespace, formatPermissions(missing))
+		os.Exit(1)
+	}
+}
+
+func formatPermissions(permissions []k8s.Permission) string {
+	formatted := make([]string, len(permissions))
+	for i, permission := range permissions {
+		formatted[i] = permission.String()
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+func withoutPermission(permissions []k8s.Permission, remove k8s.Permission) []k8s.Permission {
+	var result []k8s.Permission
+	for _, permission := range permissions {
+		if permission != remove {
+			result = append(result, permission)
+		}
+	}
+
+	return result
+}
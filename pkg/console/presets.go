@@ -0,0 +1,124 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/google/shlex"
+	"github.com/micke/kubeconsole/pkg/k8s"
+)
+
+// presetsAnnotation declares named commands on a source, separated by commas or newlines.
+// For example: console=bundle exec rails console, sandbox=bundle exec rails console --sandbox
+const presetsAnnotation = "kubeconsole.presets"
+
+type preset struct {
+	Name    string
+	Command []string
+}
+
+func parsePresets(annotations map[string]string) ([]preset, error) {
+	value := annotations[presetsAnnotation]
+	if value == "" {
+		return nil, nil
+	}
+
+	var presets []preset
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		name, command, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid preset %q, expected name=command", entry)
+		}
+
+		words, err := shlex.Split(command)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preset %q: %w", name, err)
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("invalid preset %q: empty command", name)
+		}
+
+		presets = append(presets, preset{Name: strings.TrimSpace(name), Command: words})
+	}
+
+	return presets, nil
+}
+
+// PresetNamesWithPrefix returns the names of the presets declared on the sources
+// matching sourceName that begins with the passed prefix
+func PresetNamesWithPrefix(sources []k8s.Source, sourceName string, prefix string) []string {
+	matchingPresets := []string{}
+
+	for _, s := range sources {
+		if !strings.HasPrefix(s.Name, sourceName) {
+			continue
+		}
+
+		presets, _ := parsePresets(s.Annotations)
+		for _, p := range presets {
+			if strings.HasPrefix(p.Name, prefix) {
+				matchingPresets = append(matchingPresets, p.Name)
+			}
+		}
+	}
+
+	return matchingPresets
+}
+
+// selectPreset returns the command of the preset specified with --preset, or lets the user
+// pick a preset if the source declares any and no command was specified
+func selectPreset(source *k8s.Source, options Options) []string {
+	presets, err := parsePresets(source.Annotations)
+	if err != nil && options.Preset != "" {
+		fmt.Fprintf(os.Stderr, "Invalid %s annotation on %s: %s\n", presetsAnnotation, source.DisplayName(), err)
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring %s annotation on %s: %s\n", presetsAnnotation, source.DisplayName(), err)
+		return nil
+	}
+
+	if options.Preset != "" {
+		names := make([]string, len(presets))
+		for i, p := range presets {
+			if p.Name == options.Preset {
+				return p.Command
+			}
+			names[i] = p.Name
+		}
+
+		fmt.Fprintf(os.Stderr, "No preset named %s found on %s, available presets are: %s\n", options.Preset, source.DisplayName(), strings.Join(names, ", "))
+		os.Exit(1)
+	}
+
+	if len(presets) == 0 || len(options.Command) > 0 || options.Entrypoint != "" || options.Shell != "" {
+		return nil
+	}
+
+	presetOptions := make([]string, len(presets)+1)
+	presetOptions[0] = "Default command"
+	for i, p := range presets {
+		presetOptions[i+1] = fmt.Sprintf("%s: %s", p.Name, strings.Join(p.Command, " "))
+	}
+
+	selectedPreset := 0
+	prompt := &survey.Select{
+		Message: "Choose a command:",
+		Options: presetOptions,
+	}
+	err = survey.AskOne(prompt, &selectedPreset)
+	if err == terminal.InterruptErr {
+		fmt.Println("Cancelled")
+		os.Exit(0)
+	} else if err != nil {
+		panic(err)
+	}
+
+	if selectedPreset == 0 {
+		return nil
+	}
+
+	return presets[selectedPreset-1].Command
+}
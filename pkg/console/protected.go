@@ -0,0 +1,76 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const (
+	// protectedAnnotation marks a namespace or deployment as protected when set to true
+	protectedAnnotation = "kubeconsole.protected"
+	// protectedEnv is set to true in the console container when the environment is protected
+	protectedEnv = "KUBECONSOLE_PROTECTED"
+	// environmentEnv is set to the name of the environment in the console container
+	environmentEnv = "KUBECONSOLE_ENVIRONMENT"
+)
+
+// isProtected returns true if the environment is listed as protected in the config file, or if the
+// source or its namespace is annotated as protected. Namespaces that can't be read are protected
+// when protect-unreadable-namespaces is set in the config file.
+func isProtected(k8sClient *k8s.K8s, source *k8s.Source, namespace *apiv1.Namespace, options Options) bool {
+	if slices.Contains(options.ProtectedEnvs, k8sClient.CurrentContext) {
+		return true
+	}
+
+	if protected, _ := strconv.ParseBool(source.Annotations[protectedAnnotation]); protected {
+		return true
+	}
+
+	// Namespace scoped users usually can't read the namespace, protecting it anyway keeps them from
+	// bypassing the annotation
+	if namespace == nil && options.ProtectUnknown {
+		fmt.Fprintf(os.Stderr, "Treating the %s namespace as protected since it can't be read\n", source.Namespace)
+		return true
+	} else if namespace == nil {
+		return false
+	}
+	protected, _ := strconv.ParseBool(namespace.Annotations[protectedAnnotation])
+
+	return protected
+}
+
+// applyProtected exports the environment into the container so that the app can show it, for
+// example in its prompt
+func applyProtected(container *apiv1.Container, environment string, protected bool) {
+	setEnv(container, apiv1.EnvVar{Name: environmentEnv, Value: environment})
+	setEnv(container, apiv1.EnvVar{Name: protectedEnv, Value: strconv.FormatBool(protected)})
+}
+
+// confirmProtected shows a banner and exits unless the name of the environment is typed
+func confirmProtected(environment string) {
+	fmt.Fprintf(os.Stderr, "\033[1;97;41m %s is a protected environment \033[0m\n", environment)
+
+	var answer string
+	prompt := &survey.Input{
+		Message: fmt.Sprintf("Type %s to continue:", environment),
+	}
+	err := survey.AskOne(prompt, &answer)
+	if err == terminal.InterruptErr {
+		fmt.Println("Cancelled")
+		os.Exit(0)
+	} else if err != nil {
+		panic(err)
+	}
+
+	if answer != environment {
+		fmt.Fprintf(os.Stderr, "%q does not match %s, aborting\n", answer, environment)
+		os.Exit(1)
+	}
+}
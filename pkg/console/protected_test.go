@@ -0,0 +1,38 @@
+package console
+
+import (
+	"testing"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsProtected(t *testing.T) {
+	protectedNamespace := &apiv1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{protectedAnnotation: "true"}},
+	}
+
+	tests := []struct {
+		name      string
+		namespace *apiv1.Namespace
+		options   Options
+		protected bool
+	}{
+		{name: "readable namespace", namespace: &apiv1.Namespace{}},
+		{name: "protected namespace", namespace: protectedNamespace, protected: true},
+		{name: "protected context", namespace: &apiv1.Namespace{}, options: Options{ProtectedEnvs: []string{"production"}}, protected: true},
+		{name: "unreadable namespace"},
+		{name: "unreadable namespace with other contexts protected", options: Options{ProtectedEnvs: []string{"staging"}}},
+		{name: "unreadable namespace protected", options: Options{ProtectUnknown: true}, protected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			k8sClient := &k8s.K8s{CurrentContext: "production"}
+			if protected := isProtected(k8sClient, testSource(), test.namespace, test.options); protected != test.protected {
+				t.Errorf("expected protected to be %t, got %t", test.protected, protected)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
+)
+
+// reasonAnnotation holds the justification for running the console
+const reasonAnnotation = "kubeconsole.reason"
+
+// validateReason returns an error if the reason doesn't match the pattern from the config file
+func validateReason(reason string, pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+
+	expression, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid reason-pattern %q in the config file: %w", pattern, err)
+	}
+
+	if !expression.MatchString(reason) {
+		return fmt.Errorf("the reason %q does not match %s", reason, pattern)
+	}
+
+	return nil
+}
+
+// selectReason returns the reason passed with --reason, prompting for one if none was passed and the
+// environment is protected. The reason is validated against the pattern from the config file.
+func selectReason(options Options, protected bool) string {
+	reason := options.Reason
+
+	if reason == "" && protected {
+		prompt := &survey.Input{
+			Message: "Reason for accessing a protected environment:",
+		}
+		validator := func(answer interface{}) error {
+			if answer.(string) == "" {
+				return errors.New("a reason is required")
+			}
+			return validateReason(answer.(string), options.ReasonPattern)
+		}
+		err := survey.AskOne(prompt, &reason, survey.WithValidator(validator))
+		if err == terminal.InterruptErr {
+			fmt.Println("Cancelled")
+			os.Exit(0)
+		} else if err != nil {
+			panic(err)
+		}
+
+		return reason
+	}
+
+	if reason != "" {
+		if err := validateReason(reason, options.ReasonPattern); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid reason: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	return reason
+}
@@ -0,0 +1,143 @@
+package console
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/micke/kubeconsole/pkg/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/kubectl/pkg/cmd/util/podcmd"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+// ScaffoldOptions defines how a console deployment is scaffolded
+type ScaffoldOptions struct {
+	Namespace     string
+	Name          string
+	LabelSelector string
+	ContainerName string
+	Apply         bool
+	Output        string
+}
+
+// suggestedCommands maps words found in the command or image of the app to a console command
+var suggestedCommands = []struct {
+	Match   []string
+	Command []string
+}{
+	{Match: []string{"rails", "puma", "unicorn", "sidekiq"}, Command: []string{"bundle", "exec", "rails", "console"}},
+	{Match: []string{"manage.py", "django", "gunicorn"}, Command: []string{"python", "manage.py", "shell"}},
+	{Match: []string{"node", "npm", "yarn"}, Command: []string{"node"}},
+	{Match: []string{"iex", "elixir", "mix"}, Command: []string{"iex", "-S", "mix"}},
+}
+
+// Scaffold reads an app deployment and prints, or applies, a console deployment based on it
+func Scaffold(k8s *k8s.K8s, deploymentName string, options ScaffoldOptions) error {
+	namespace := options.Namespace
+	if namespace == "" {
+		namespace = k8s.ContextNamespace()
+	}
+
+	deployment, err := k8s.Clientset.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	consoleDeployment, err := scaffoldDeployment(deployment, options)
+	if err != nil {
+		return err
+	}
+
+	if options.Apply {
+		created, err := k8s.Clientset.AppsV1().Deployments(namespace).Create(context.TODO(), consoleDeployment, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Created deployment %s/%s\n", created.Namespace, created.Name)
+		return nil
+	}
+
+	var printer printers.ResourcePrinter = &printers.YAMLPrinter{}
+	if options.Output == "json" {
+		printer = &printers.JSONPrinter{}
+	}
+
+	return printers.NewTypeSetter(scheme.Scheme).ToPrinter(printer).PrintObj(consoleDeployment, os.Stdout)
+}
+
+// scaffoldDeployment returns a console deployment with zero replicas based on the app deployment.
+// Probes, ports and the app's labels are removed so that the console pods aren't restarted or
+// receive traffic from the app's services.
+func scaffoldDeployment(deployment *appsv1.Deployment, options ScaffoldOptions) (*appsv1.Deployment, error) {
+	consoleLabels, err := labels.ConvertSelectorToLabelsMap(options.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("the selector %s can't be used as labels: %w", options.LabelSelector, err)
+	}
+
+	name := options.Name
+	if name == "" {
+		name = deployment.Name + "-console"
+	}
+
+	podLabels := map[string]string{"app": name}
+	for key, value := range consoleLabels {
+		podLabels[key] = value
+	}
+
+	replicas := int32(0)
+	template := *deployment.Spec.Template.DeepCopy()
+	template.Labels = podLabels
+	delete(template.Annotations, "kubectl.kubernetes.io/restartedAt")
+
+	pod := &apiv1.Pod{ObjectMeta: template.ObjectMeta, Spec: template.Spec}
+	container, err := podcmd.FindOrDefaultContainerByName(pod, options.ContainerName, true, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	container.Command = suggestCommand(container)
+	container.Args = nil
+	template.Spec = pod.Spec
+
+	for i := range template.Spec.Containers {
+		template.Spec.Containers[i].Ports = nil
+		template.Spec.Containers[i].LivenessProbe = nil
+		template.Spec.Containers[i].ReadinessProbe = nil
+		template.Spec.Containers[i].StartupProbe = nil
+	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: deployment.Namespace,
+			Labels:    podLabels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+			Template: template,
+		},
+	}, nil
+}
+
+// suggestCommand guesses a console command from the command and image of the container,
+// falling back to a shell
+func suggestCommand(container *apiv1.Container) []string {
+	haystack := strings.Join(append(append([]string{container.Image}, container.Command...), container.Args...), " ")
+
+	for _, suggestion := range suggestedCommands {
+		for _, match := range suggestion.Match {
+			if strings.Contains(haystack, match) {
+				return suggestion.Command
+			}
+		}
+	}
+
+	return []string{"/bin/sh"}
+}
@@ -0,0 +1,270 @@
+package console
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const (
+	// SecurityProfileRestricted follows the restricted Pod Security Standard
+	SecurityProfileRestricted = "restricted"
+	// SecurityProfileBaseline follows the baseline Pod Security Standard
+	SecurityProfileBaseline = "baseline"
+	// SecurityProfileRoot runs the console as root
+	SecurityProfileRoot = "root"
+	// SecurityProfileDebug runs the console as root with the capabilities needed by debugging tools
+	SecurityProfileDebug = "debug"
+
+	// podSecurityEnforceLabel is the Pod Security Admission label holding the level enforced in a namespace
+	podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+)
+
+// SecurityProfiles are the valid values for --security-profile
+var SecurityProfiles = []string{SecurityProfileRestricted, SecurityProfileBaseline, SecurityProfileRoot, SecurityProfileDebug}
+
+// debugCapabilities are added to the console container by the debug profile
+var debugCapabilities = []apiv1.Capability{"SYS_PTRACE", "NET_ADMIN", "NET_RAW"}
+
+// baselineCapabilities are the capabilities that may be added under the baseline Pod Security Standard
+var baselineCapabilities = []apiv1.Capability{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// podSecurityLevels are the Pod Security Standards from the most to the least restrictive
+var podSecurityLevels = []string{"restricted", "baseline", "privileged"}
+
+// applySecurityProfile sets the security context of the pod and its containers for the profile.
+// The restricted and baseline profiles apply to all containers since the pod is admitted as a whole,
+// root and debug only change the console container.
+func applySecurityProfile(pod *apiv1.Pod, container *apiv1.Container, profile string) {
+	if profile == "" {
+		return
+	}
+
+	if pod.Spec.SecurityContext == nil {
+		pod.Spec.SecurityContext = &apiv1.PodSecurityContext{}
+	}
+
+	switch profile {
+	case SecurityProfileRestricted:
+		runAsNonRoot := true
+		pod.Spec.SecurityContext.RunAsNonRoot = &runAsNonRoot
+		// The kubelet refuses to start containers running as root when RunAsNonRoot is set
+		if pod.Spec.SecurityContext.RunAsUser != nil && *pod.Spec.SecurityContext.RunAsUser == 0 {
+			pod.Spec.SecurityContext.RunAsUser = nil
+		}
+		pod.Spec.SecurityContext.SeccompProfile = &apiv1.SeccompProfile{Type: apiv1.SeccompProfileTypeRuntimeDefault}
+		forEachContainer(pod, func(c *apiv1.Container) {
+			securityContext := containerSecurityContext(c)
+			allowPrivilegeEscalation := false
+			securityContext.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+			securityContext.Privileged = nil
+			securityContext.RunAsNonRoot = nil
+			if securityContext.RunAsUser != nil && *securityContext.RunAsUser == 0 {
+				securityContext.RunAsUser = nil
+			}
+			securityContext.Capabilities = &apiv1.Capabilities{Drop: []apiv1.Capability{"ALL"}}
+			// The pod seccomp profile applies to the containers without one
+			if isUnconfined(securityContext.SeccompProfile) {
+				securityContext.SeccompProfile = nil
+			}
+		})
+	case SecurityProfileBaseline:
+		if isUnconfined(pod.Spec.SecurityContext.SeccompProfile) {
+			pod.Spec.SecurityContext.SeccompProfile = nil
+		}
+		forEachContainer(pod, func(c *apiv1.Container) {
+			securityContext := containerSecurityContext(c)
+			securityContext.Privileged = nil
+			if isUnconfined(securityContext.SeccompProfile) {
+				securityContext.SeccompProfile = nil
+			}
+			if securityContext.Capabilities != nil {
+				securityContext.Capabilities.Add = slices.DeleteFunc(securityContext.Capabilities.Add, func(capability apiv1.Capability) bool {
+					return !slices.Contains(baselineCapabilities, capability)
+				})
+			}
+		})
+	case SecurityProfileRoot, SecurityProfileDebug:
+		runAsNonRoot := false
+		runAsUser := int64(0)
+		pod.Spec.SecurityContext.RunAsNonRoot = &runAsNonRoot
+		pod.Spec.SecurityContext.RunAsUser = &runAsUser
+
+		// The container security context takes precedence over the one of the pod
+		securityContext := containerSecurityContext(container)
+		securityContext.RunAsNonRoot = &runAsNonRoot
+		securityContext.RunAsUser = &runAsUser
+
+		if profile == SecurityProfileDebug {
+			if securityContext.Capabilities == nil {
+				securityContext.Capabilities = &apiv1.Capabilities{}
+			}
+			for _, capability := range debugCapabilities {
+				securityContext.Capabilities.Drop = slices.DeleteFunc(securityContext.Capabilities.Drop, func(drop apiv1.Capability) bool {
+					return drop == capability
+				})
+				if !slices.Contains(securityContext.Capabilities.Add, capability) {
+					securityContext.Capabilities.Add = append(securityContext.Capabilities.Add, capability)
+				}
+			}
+		}
+	}
+}
+
+func forEachContainer(pod *apiv1.Pod, f func(c *apiv1.Container)) {
+	for i := range pod.Spec.InitContainers {
+		f(&pod.Spec.InitContainers[i])
+	}
+	for i := range pod.Spec.Containers {
+		f(&pod.Spec.Containers[i])
+	}
+}
+
+func containerSecurityContext(container *apiv1.Container) *apiv1.SecurityContext {
+	if container.SecurityContext == nil {
+		container.SecurityContext = &apiv1.SecurityContext{}
+	}
+
+	return container.SecurityContext
+}
+
+// validatePodSecurity returns an error if the pod breaks the Pod Security Standard enforced by the
+// namespace, or the one of the restricted and baseline profiles since the pod has to follow them
+// whatever the template and overrides set. The namespace is nil when it can't be read, only the
+// profile is then checked.
+func validatePodSecurity(namespace *apiv1.Namespace, pod *apiv1.Pod, profile string) error {
+	level, reason := "privileged", ""
+	if profile == SecurityProfileRestricted || profile == SecurityProfileBaseline {
+		level, reason = profile, fmt.Sprintf("required by the %s profile", profile)
+	}
+
+	if namespace != nil {
+		enforced := namespace.Labels[podSecurityEnforceLabel]
+		if slices.Contains(podSecurityLevels, enforced) && slices.Index(podSecurityLevels, enforced) < slices.Index(podSecurityLevels, level) {
+			level, reason = enforced, fmt.Sprintf("enforced by the %s namespace", namespace.Name)
+		}
+	}
+
+	violations := podSecurityViolations(pod, level)
+	if len(violations) > 0 {
+		return fmt.Errorf("the pod breaks the %s Pod Security Standard %s: %s", level, reason, strings.Join(violations, ", "))
+	}
+
+	return nil
+}
+
+// podSecurityViolations returns how the pod breaks the controls of the Pod Security Standard
+// level that don't depend on the node, such as AppArmor and SELinux
+func podSecurityViolations(pod *apiv1.Pod, level string) []string {
+	if level != SecurityProfileRestricted && level != SecurityProfileBaseline {
+		return nil
+	}
+
+	var violations []string
+	podSecurityContext := pod.Spec.SecurityContext
+	if podSecurityContext == nil {
+		podSecurityContext = &apiv1.PodSecurityContext{}
+	}
+
+	if pod.Spec.HostNetwork {
+		violations = append(violations, "hostNetwork is set")
+	}
+	if pod.Spec.HostPID {
+		violations = append(violations, "hostPID is set")
+	}
+	if pod.Spec.HostIPC {
+		violations = append(violations, "hostIPC is set")
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.HostPath != nil {
+			violations = append(violations, fmt.Sprintf("the volume %s is a hostPath", volume.Name))
+		} else if level == SecurityProfileRestricted && !restrictedVolume(volume) {
+			violations = append(violations, fmt.Sprintf("the volume %s has a type that isn't allowed", volume.Name))
+		}
+	}
+	if isUnconfined(podSecurityContext.SeccompProfile) {
+		violations = append(violations, "the pod seccomp profile is Unconfined")
+	}
+
+	forEachContainer(pod, func(c *apiv1.Container) {
+		securityContext := c.SecurityContext
+		if securityContext == nil {
+			securityContext = &apiv1.SecurityContext{}
+		}
+
+		if securityContext.Privileged != nil && *securityContext.Privileged {
+			violations = append(violations, fmt.Sprintf("the %s container is privileged", c.Name))
+		}
+		for _, port := range c.Ports {
+			if port.HostPort != 0 {
+				violations = append(violations, fmt.Sprintf("the %s container uses the host port %d", c.Name, port.HostPort))
+			}
+		}
+		if isUnconfined(securityContext.SeccompProfile) {
+			violations = append(violations, fmt.Sprintf("the %s container seccomp profile is Unconfined", c.Name))
+		}
+		if securityContext.ProcMount != nil && *securityContext.ProcMount == apiv1.UnmaskedProcMount {
+			violations = append(violations, fmt.Sprintf("the %s container uses an unmasked proc mount", c.Name))
+		}
+
+		var added []apiv1.Capability
+		if securityContext.Capabilities != nil {
+			added = securityContext.Capabilities.Add
+		}
+		allowed := baselineCapabilities
+		if level == SecurityProfileRestricted {
+			allowed = []apiv1.Capability{"NET_BIND_SERVICE"}
+		}
+		for _, capability := range added {
+			if !slices.Contains(allowed, capability) {
+				violations = append(violations, fmt.Sprintf("the %s container adds the %s capability", c.Name, capability))
+			}
+		}
+
+		if level != SecurityProfileRestricted {
+			return
+		}
+
+		if securityContext.AllowPrivilegeEscalation == nil || *securityContext.AllowPrivilegeEscalation {
+			violations = append(violations, fmt.Sprintf("the %s container doesn't set allowPrivilegeEscalation to false", c.Name))
+		}
+		if securityContext.Capabilities == nil || !slices.Contains(securityContext.Capabilities.Drop, "ALL") {
+			violations = append(violations, fmt.Sprintf("the %s container doesn't drop ALL capabilities", c.Name))
+		}
+		runAsNonRoot := podSecurityContext.RunAsNonRoot
+		if securityContext.RunAsNonRoot != nil {
+			runAsNonRoot = securityContext.RunAsNonRoot
+		}
+		if runAsNonRoot == nil || !*runAsNonRoot {
+			violations = append(violations, fmt.Sprintf("the %s container doesn't set runAsNonRoot", c.Name))
+		}
+		if runsAsRoot(pod, c) {
+			violations = append(violations, fmt.Sprintf("the %s container runs as root", c.Name))
+		}
+		seccompProfile := podSecurityContext.SeccompProfile
+		if securityContext.SeccompProfile != nil {
+			seccompProfile = securityContext.SeccompProfile
+		}
+		if seccompProfile == nil || (seccompProfile.Type != apiv1.SeccompProfileTypeRuntimeDefault && seccompProfile.Type != apiv1.SeccompProfileTypeLocalhost) {
+			violations = append(violations, fmt.Sprintf("the %s container doesn't use the RuntimeDefault or a Localhost seccomp profile", c.Name))
+		}
+	})
+
+	return violations
+}
+
+// restrictedVolume returns true if the volume type is allowed by the restricted Pod Security Standard
+func restrictedVolume(volume apiv1.Volume) bool {
+	source := volume.VolumeSource
+	return source.ConfigMap != nil || source.CSI != nil || source.DownwardAPI != nil || source.EmptyDir != nil ||
+		source.Ephemeral != nil || source.PersistentVolumeClaim != nil || source.Projected != nil || source.Secret != nil
+}
+
+func isUnconfined(seccompProfile *apiv1.SeccompProfile) bool {
+	return seccompProfile != nil && seccompProfile.Type == apiv1.SeccompProfileTypeUnconfined
+}
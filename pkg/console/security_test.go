@@ -0,0 +1,80 @@
+package console
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidatePodSecurity(t *testing.T) {
+	restrictedNamespace := &apiv1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Labels: map[string]string{podSecurityEnforceLabel: "restricted"}},
+	}
+	baselineNamespace := &apiv1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Labels: map[string]string{podSecurityEnforceLabel: "baseline"}},
+	}
+
+	tests := []struct {
+		name      string
+		namespace *apiv1.Namespace
+		profile   string
+		change    func(pod *apiv1.Pod)
+		valid     bool
+	}{
+		{name: "restricted profile", profile: SecurityProfileRestricted, valid: true},
+		{name: "restricted profile in restricted namespace", namespace: restrictedNamespace, profile: SecurityProfileRestricted, valid: true},
+		{name: "no profile in restricted namespace", namespace: restrictedNamespace},
+		{name: "no profile in unreadable namespace", valid: true},
+		{name: "root profile in restricted namespace", namespace: restrictedNamespace, profile: SecurityProfileRoot},
+		{name: "root profile in baseline namespace", namespace: baselineNamespace, profile: SecurityProfileRoot, valid: true},
+		{name: "debug profile in baseline namespace", namespace: baselineNamespace, profile: SecurityProfileDebug},
+		{
+			name:    "restricted profile with host network",
+			profile: SecurityProfileRestricted,
+			change:  func(pod *apiv1.Pod) { pod.Spec.HostNetwork = true },
+		},
+		{
+			name:    "restricted profile with host path",
+			profile: SecurityProfileRestricted,
+			change: func(pod *apiv1.Pod) {
+				pod.Spec.Volumes = []apiv1.Volume{{Name: "host", VolumeSource: apiv1.VolumeSource{HostPath: &apiv1.HostPathVolumeSource{Path: "/"}}}}
+			},
+		},
+		{
+			name:    "restricted profile with unconfined container",
+			profile: SecurityProfileRestricted,
+			change: func(pod *apiv1.Pod) {
+				pod.Spec.Containers[0].SecurityContext = &apiv1.SecurityContext{SeccompProfile: &apiv1.SeccompProfile{Type: apiv1.SeccompProfileTypeUnconfined}}
+			},
+			valid: true,
+		},
+		{
+			name:      "baseline profile with privileged sidecar",
+			namespace: baselineNamespace,
+			profile:   SecurityProfileBaseline,
+			change: func(pod *apiv1.Pod) {
+				privileged := true
+				pod.Spec.Containers = append(pod.Spec.Containers, apiv1.Container{Name: "sidecar", SecurityContext: &apiv1.SecurityContext{Privileged: &privileged}})
+			},
+			valid: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := podFromSource(testSource())
+			if test.change != nil {
+				test.change(pod)
+			}
+			applySecurityProfile(pod, &pod.Spec.Containers[0], test.profile)
+
+			err := validatePodSecurity(test.namespace, pod, test.profile)
+			if test.valid && err != nil {
+				t.Errorf("expected the pod to be valid, got %s", err)
+			} else if !test.valid && err == nil {
+				t.Error("expected the pod to be invalid")
+			}
+		})
+	}
+}
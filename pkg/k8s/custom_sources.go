@@ -0,0 +1,134 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// CustomSource describes a custom resource that embeds a pod template, configured in the
+// kubeconsole config file under sources
+type CustomSource struct {
+	// Resource is the resource in group/version/resource format, for example argoproj.io/v1alpha1/rollouts
+	Resource string `mapstructure:"resource"`
+	// Template is a JSONPath expression to the pod template, for example {.spec.template}
+	Template string `mapstructure:"template"`
+	// SingularKind is the kind used with --kind, derived from the resource if not set
+	SingularKind string `mapstructure:"kind"`
+}
+
+// Kind returns the singular, lower case name used to select the custom source with --kind,
+// matching the built in kinds and the kind shown when picking a console
+func (c CustomSource) Kind() string {
+	if c.SingularKind != "" {
+		return strings.ToLower(c.SingularKind)
+	}
+
+	return singular(strings.ToLower(c.groupVersionResource().Resource))
+}
+
+// matchesKind returns true if the kind is the singular kind or the plural resource name
+func (c CustomSource) matchesKind(kind string) bool {
+	return kind == c.Kind() || kind == strings.ToLower(c.groupVersionResource().Resource)
+}
+
+// singular returns the singular form of a plural resource name following the English rules
+// resource names use, for example rollouts, policies and prefixes
+func singular(resource string) string {
+	switch {
+	case strings.HasSuffix(resource, "ies"):
+		return strings.TrimSuffix(resource, "ies") + "y"
+	case strings.HasSuffix(resource, "sses"), strings.HasSuffix(resource, "xes"), strings.HasSuffix(resource, "ches"), strings.HasSuffix(resource, "shes"):
+		return strings.TrimSuffix(resource, "es")
+	default:
+		return strings.TrimSuffix(resource, "s")
+	}
+}
+
+// Validate returns an error if the resource or template can't be parsed
+func (c CustomSource) Validate() error {
+	if strings.Count(c.Resource, "/") < 1 || strings.Count(c.Resource, "/") > 2 {
+		return fmt.Errorf("invalid resource %q, expected group/version/resource", c.Resource)
+	}
+
+	if err := jsonpath.New(c.Resource).Parse(c.Template); err != nil {
+		return fmt.Errorf("invalid template %q for %s: %w", c.Template, c.Resource, err)
+	}
+
+	return nil
+}
+
+func (c CustomSource) groupVersionResource() schema.GroupVersionResource {
+	parts := strings.Split(c.Resource, "/")
+	if len(parts) == 2 {
+		// Resources in the core group don't have a group
+		return schema.GroupVersionResource{Version: parts[0], Resource: parts[1]}
+	}
+
+	return schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[len(parts)-1]}
+}
+
+func (c CustomSource) lister() sourceLister {
+	return func(k8s *K8s, namespace string, listOptions metav1.ListOptions) ([]Source, error) {
+		list, err := k8s.DynamicClient.Resource(c.groupVersionResource()).Namespace(namespace).List(context.TODO(), listOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		sources := make([]Source, 0, len(list.Items))
+		for _, item := range list.Items {
+			template, err := c.podTemplate(item)
+			if err != nil {
+				return nil, err
+			}
+
+			sources = append(sources, Source{
+				TypeMeta: metav1.TypeMeta{APIVersion: item.GetAPIVersion(), Kind: item.GetKind()},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        item.GetName(),
+					Namespace:   item.GetNamespace(),
+					UID:         item.GetUID(),
+					Labels:      item.GetLabels(),
+					Annotations: item.GetAnnotations(),
+				},
+				Template: template,
+			})
+		}
+
+		return sources, nil
+	}
+}
+
+// podTemplate extracts the pod template from the custom resource using the JSONPath template
+func (c CustomSource) podTemplate(item unstructured.Unstructured) (apiv1.PodTemplateSpec, error) {
+	var template apiv1.PodTemplateSpec
+
+	path := jsonpath.New(c.Resource)
+	if err := path.Parse(c.Template); err != nil {
+		return template, err
+	}
+
+	results, err := path.FindResults(item.Object)
+	if err != nil {
+		return template, fmt.Errorf("finding pod template in %s/%s: %w", c.Kind(), item.GetName(), err)
+	}
+	if len(results) == 0 || len(results[0]) == 0 {
+		return template, fmt.Errorf("no pod template found in %s/%s at %s", c.Kind(), item.GetName(), c.Template)
+	}
+
+	object, ok := results[0][0].Interface().(map[string]interface{})
+	if !ok {
+		return template, fmt.Errorf("the pod template in %s/%s at %s is not an object", c.Kind(), item.GetName(), c.Template)
+	}
+
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(object, &template)
+
+	return template, err
+}
@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+// SourcesFromFile reads the sources defined in a local manifest file with one or more documents,
+// reading from stdin if the path is -. Documents that aren't sources are skipped.
+func SourcesFromFile(path string) ([]Source, error) {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var sources []Source
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(in))
+	decoder := scheme.Codecs.UniversalDeserializer()
+
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		obj, _, err := decoder.Decode(document, nil, nil)
+		if runtime.IsMissingKind(err) || runtime.IsNotRegisteredError(err) {
+			// Empty documents, for example a trailing ---, and custom resources can't be used as sources
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", path, err)
+		}
+
+		source, ok := SourceFromObject(obj)
+		if !ok {
+			continue
+		}
+
+		// The cluster defaults the replicas of Deployments and StatefulSets to 1 when not set
+		if source.Replicas == nil && (source.Kind == "Deployment" || source.Kind == "StatefulSet") {
+			replicas := int32(1)
+			source.Replicas = &replicas
+		}
+
+		sources = append(sources, source)
+	}
+
+	return sources, nil
+}
@@ -5,9 +5,10 @@ import (
 	"sort"
 	"strings"
 
-	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -17,24 +18,26 @@ import (
 
 // K8s is a struct of the commonly used kubernetes client
 type K8s struct {
-	Config     api.Config
-	RestConfig *rest.Config
-	Clientset  *kubernetes.Clientset
-	Contexts   map[string]*api.Context
+	Config        api.Config
+	RestConfig    *rest.Config
+	Clientset     *kubernetes.Clientset
+	DynamicClient dynamic.Interface
+	Contexts      map[string]*api.Context
+	CustomSources []CustomSource
+	// CurrentContext is the name of the context selected with SelectContext
+	CurrentContext string
 }
 
-// NewK8s initializes a K8s
-func NewK8s(kubeconfig string) *K8s {
+// NewK8s initializes a K8s, if the kubeconfig can't be loaded a K8s without any contexts is
+// returned together with the error
+func NewK8s(kubeconfig string) (*K8s, error) {
 	clientConfig := clientConfig(kubeconfig)
 	config, err := clientConfig.RawConfig()
-	if err != nil {
-		panic(err)
-	}
 
 	return &K8s{
 		Config:   config,
 		Contexts: config.Contexts,
-	}
+	}, err
 }
 
 // ContextNames returns the contexts available in a kubeconfig
@@ -63,34 +66,37 @@ func (k8s *K8s) ContextNamesWithPrefix(prefix string) []string {
 	return matchingContexts
 }
 
-// Deployments returns a list of deployments
-func (k8s *K8s) Deployments(labelSelector string) []appsv1.Deployment {
-	deploymentsClient := k8s.Clientset.AppsV1().Deployments("")
-
-	list, err := deploymentsClient.List(context.TODO(), metav1.ListOptions{LabelSelector: labelSelector})
-	if err != nil {
-		panic(err)
+// ContextNamespace returns the namespace of the selected context or default if it doesn't have one
+func (k8s *K8s) ContextNamespace() string {
+	if context := k8s.Contexts[k8s.CurrentContext]; context != nil && context.Namespace != "" {
+		return context.Namespace
 	}
-	deployments := list.Items
 
-	return deployments
+	return metav1.NamespaceDefault
 }
 
-// DeploymentNamesWithPrefix returns the context names that begins with the passed prefix
-func (k8s *K8s) DeploymentNamesWithPrefix(prefix string, labelSelector string) []string {
-	matchingDeploys := []string{}
+// SelectContext selects a context to connect the clientset to
+func (k8s *K8s) SelectContext(context string) {
+	if err := k8s.TrySelectContext(context); err != nil {
+		panic(err)
+	}
+}
 
-	for _, deploy := range k8s.Deployments(labelSelector) {
-		if strings.HasPrefix(deploy.Name, prefix) {
-			matchingDeploys = append(matchingDeploys, deploy.Name)
-		}
+// ForContext returns a new K8s connected to the context, leaving this one untouched so that
+// several contexts can be used concurrently
+func (k8s *K8s) ForContext(context string) (*K8s, error) {
+	contextK8s := &K8s{
+		Config:        k8s.Config,
+		Contexts:      k8s.Contexts,
+		CustomSources: k8s.CustomSources,
 	}
 
-	return matchingDeploys
+	return contextK8s, contextK8s.TrySelectContext(context)
 }
 
-// SelectContext selects a context to connect the clientset to
-func (k8s *K8s) SelectContext(context string) {
+// TrySelectContext selects a context to connect the clientset to, returning an error if the
+// client can't be configured
+func (k8s *K8s) TrySelectContext(context string) error {
 	override := &clientcmd.ConfigOverrides{CurrentContext: context}
 	clientConfig := clientcmd.NewNonInteractiveClientConfig(
 		k8s.Config,
@@ -101,7 +107,7 @@ func (k8s *K8s) SelectContext(context string) {
 
 	config, err := clientConfig.ClientConfig()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	config.GroupVersion = &schema.GroupVersion{Group: "", Version: "v1"}
@@ -110,11 +116,44 @@ func (k8s *K8s) SelectContext(context string) {
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
 	}
 
+	k8s.CurrentContext = context
 	k8s.RestConfig = config
 	k8s.Clientset = clientset
+	k8s.DynamicClient = dynamicClient
+
+	return nil
+}
+
+// Username returns the username the cluster authenticates the current user as
+func (k8s *K8s) Username() (string, error) {
+	userInfo, err := k8s.UserInfo()
+	if err != nil {
+		return "", err
+	}
+
+	return userInfo.Username, nil
+}
+
+// UserInfo returns the user and groups the cluster authenticates the client as
+func (k8s *K8s) UserInfo() (authenticationv1.UserInfo, error) {
+	review, err := k8s.Clientset.AuthenticationV1().SelfSubjectReviews().Create(
+		context.TODO(),
+		&authenticationv1.SelfSubjectReview{},
+		metav1.CreateOptions{},
+	)
+	if err != nil {
+		return authenticationv1.UserInfo{}, err
+	}
+
+	return review.Status.UserInfo, nil
 }
 
 func clientConfig(kubeconfig string) clientcmd.ClientConfig {
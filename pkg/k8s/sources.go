@@ -0,0 +1,253 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Source is a resource that holds a pod template that console pods can be created from
+type Source struct {
+	metav1.TypeMeta
+	metav1.ObjectMeta
+	Template apiv1.PodTemplateSpec
+	// Replicas is the number of replicas of a Deployment or StatefulSet
+	Replicas *int32
+	// VolumeClaimTemplates are the claims of a StatefulSet, the pods volumes refer to them by name
+	VolumeClaimTemplates []apiv1.PersistentVolumeClaim
+}
+
+// DisplayName returns the name of the source prefixed by its kind, for example deployment/console
+func (s Source) DisplayName() string {
+	return fmt.Sprintf("%s/%s", strings.ToLower(s.Kind), s.Name)
+}
+
+type sourceLister func(k8s *K8s, namespace string, listOptions metav1.ListOptions) ([]Source, error)
+
+// sourceListers holds how to list each kind of source
+var sourceListers = map[string]sourceLister{
+	"deployment": builtinLister(func(k8s *K8s, namespace string, listOptions metav1.ListOptions) (runtime.Object, error) {
+		return k8s.Clientset.AppsV1().Deployments(namespace).List(context.TODO(), listOptions)
+	}),
+	"podtemplate": builtinLister(func(k8s *K8s, namespace string, listOptions metav1.ListOptions) (runtime.Object, error) {
+		return k8s.Clientset.CoreV1().PodTemplates(namespace).List(context.TODO(), listOptions)
+	}),
+	"statefulset": builtinLister(func(k8s *K8s, namespace string, listOptions metav1.ListOptions) (runtime.Object, error) {
+		return k8s.Clientset.AppsV1().StatefulSets(namespace).List(context.TODO(), listOptions)
+	}),
+	"daemonset": builtinLister(func(k8s *K8s, namespace string, listOptions metav1.ListOptions) (runtime.Object, error) {
+		return k8s.Clientset.AppsV1().DaemonSets(namespace).List(context.TODO(), listOptions)
+	}),
+	"job": builtinLister(func(k8s *K8s, namespace string, listOptions metav1.ListOptions) (runtime.Object, error) {
+		return k8s.Clientset.BatchV1().Jobs(namespace).List(context.TODO(), listOptions)
+	}),
+	"cronjob": builtinLister(func(k8s *K8s, namespace string, listOptions metav1.ListOptions) (runtime.Object, error) {
+		return k8s.Clientset.BatchV1().CronJobs(namespace).List(context.TODO(), listOptions)
+	}),
+}
+
+// SourceKinds returns the kinds of resources that can be used as sources, including the custom sources
+func (k8s *K8s) SourceKinds() []string {
+	kinds := make([]string, 0, len(sourceListers)+len(k8s.CustomSources))
+	for kind := range sourceListers {
+		kinds = append(kinds, kind)
+	}
+	for _, customSource := range k8s.CustomSources {
+		kinds = append(kinds, customSource.Kind())
+	}
+
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+// Sources returns all the sources of the given kinds matching the label selector in the namespaces.
+// If no kinds are given all kinds are searched, skipping the kinds that can't be listed.
+// If no namespaces are given all namespaces are searched, falling back to the namespace of the
+// context if listing in all namespaces is forbidden.
+func (k8s *K8s) Sources(labelSelector string, kinds []string, namespaces []string) ([]Source, error) {
+	discover := len(kinds) == 0
+	if discover {
+		kinds = k8s.SourceKinds()
+	}
+
+	var sources []Source
+	for _, kind := range kinds {
+		lister, ok := k8s.sourceLister(strings.ToLower(kind))
+		if !ok {
+			return nil, fmt.Errorf("unknown source kind %s, valid kinds are: %s", kind, strings.Join(k8s.SourceKinds(), ", "))
+		}
+
+		kindSources, err := k8s.listSources(lister, metav1.ListOptions{LabelSelector: labelSelector}, namespaces)
+		if discover && (apierrors.IsForbidden(err) || apierrors.IsNotFound(err)) {
+			// Lacking permissions for, or the cluster not serving, a kind shouldn't stop the other kinds from being used
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, kindSources...)
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Name < sources[j].Name
+	})
+
+	return sources, nil
+}
+
+func (k8s *K8s) listSources(lister sourceLister, listOptions metav1.ListOptions, namespaces []string) ([]Source, error) {
+	if len(namespaces) == 0 {
+		sources, err := lister(k8s, metav1.NamespaceAll, listOptions)
+		if !apierrors.IsForbidden(err) {
+			return sources, err
+		}
+
+		namespaces = []string{k8s.ContextNamespace()}
+	}
+
+	var sources []Source
+	for _, namespace := range namespaces {
+		namespaceSources, err := lister(k8s, namespace, listOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, namespaceSources...)
+	}
+
+	return sources, nil
+}
+
+func (k8s *K8s) sourceLister(kind string) (sourceLister, bool) {
+	if lister, ok := sourceListers[kind]; ok {
+		return lister, true
+	}
+
+	for _, customSource := range k8s.CustomSources {
+		if customSource.matchesKind(kind) {
+			return customSource.lister(), true
+		}
+	}
+
+	return nil, false
+}
+
+// HasSourceKind returns true if the kind can be used as a source, custom sources can be referred
+// to by their singular kind or plural resource name
+func (k8s *K8s) HasSourceKind(kind string) bool {
+	_, ok := k8s.sourceLister(strings.ToLower(kind))
+	return ok
+}
+
+// SourceNamesWithPrefix returns the source names that begins with the passed prefix
+func (k8s *K8s) SourceNamesWithPrefix(prefix string, labelSelector string, kinds []string, namespaces []string) []string {
+	matchingSources := []string{}
+	sources, _ := k8s.Sources(labelSelector, kinds, namespaces)
+
+	for _, source := range sources {
+		if strings.HasPrefix(source.Name, prefix) && !slices.Contains(matchingSources, source.Name) {
+			matchingSources = append(matchingSources, source.Name)
+		}
+	}
+
+	return matchingSources
+}
+
+// builtinLister returns a sourceLister for a built in kind, list returns the list of the kind
+func builtinLister(list func(k8s *K8s, namespace string, listOptions metav1.ListOptions) (runtime.Object, error)) sourceLister {
+	return func(k8s *K8s, namespace string, listOptions metav1.ListOptions) ([]Source, error) {
+		obj, err := list(k8s, namespace, listOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		items, err := meta.ExtractList(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		sources := make([]Source, len(items))
+		for i, item := range items {
+			sources[i], _ = SourceFromObject(item)
+		}
+
+		return sources, nil
+	}
+}
+
+// SourceFromObject returns the source for a resource holding a pod template, the second
+// return value is false if the resource isn't one of the built in source kinds
+func SourceFromObject(obj runtime.Object) (Source, bool) {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return Source{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: o.ObjectMeta,
+			Template:   o.Spec.Template,
+			Replicas:   o.Spec.Replicas,
+		}, true
+	case *apiv1.PodTemplate:
+		return Source{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PodTemplate"},
+			ObjectMeta: o.ObjectMeta,
+			Template:   o.Template,
+		}, true
+	case *appsv1.StatefulSet:
+		return Source{
+			TypeMeta:             metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+			ObjectMeta:           o.ObjectMeta,
+			Template:             o.Spec.Template,
+			Replicas:             o.Spec.Replicas,
+			VolumeClaimTemplates: o.Spec.VolumeClaimTemplates,
+		}, true
+	case *appsv1.DaemonSet:
+		return Source{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"},
+			ObjectMeta: o.ObjectMeta,
+			Template:   withoutSelectorLabels(o.Spec.Template, o.Spec.Selector),
+		}, true
+	case *batchv1.Job:
+		return Source{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+			ObjectMeta: o.ObjectMeta,
+			Template:   withoutSelectorLabels(o.Spec.Template, o.Spec.Selector),
+		}, true
+	case *batchv1.CronJob:
+		return Source{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+			ObjectMeta: o.ObjectMeta,
+			Template:   o.Spec.JobTemplate.Spec.Template,
+		}, true
+	default:
+		return Source{}, false
+	}
+}
+
+// withoutSelectorLabels removes the labels matched by the selector from the template, DaemonSets
+// and Jobs adopt orphaned pods matching their selector which would make them manage the console pod
+func withoutSelectorLabels(template apiv1.PodTemplateSpec, selector *metav1.LabelSelector) apiv1.PodTemplateSpec {
+	if selector == nil {
+		return template
+	}
+
+	labels := make(map[string]string, len(template.Labels))
+	for key, value := range template.Labels {
+		if _, ok := selector.MatchLabels[key]; !ok {
+			labels[key] = value
+		}
+	}
+	template.Labels = labels
+
+	return template
+}